@@ -0,0 +1,6 @@
+package data
+
+import "embed"
+
+//go:embed markers/*
+var Markers embed.FS
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth guards admin endpoints with a bearer token taken from the
+// ADMIN_TOKEN environment variable. Without one, admin endpoints are disabled.
+func AdminAuth() gin.HandlerFunc {
+	token := os.Getenv("ADMIN_TOKEN")
+
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin endpoints are disabled"})
+			return
+		}
+
+		supplied, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing admin token"})
+			return
+		}
+
+		c.Next()
+	}
+}
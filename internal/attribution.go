@@ -1,6 +1,108 @@
 package internal
 
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
 var ATTRIBUTION = []string{
 	"Geographic Data Service, https://data.geods.ac.uk/dataset/point-of-interest-data-for-the-united-kingdom",
 	"Map Markers, https://mapicons.mapsmarker.com",
 }
+
+// LoadSourceAttribution reads a JSON object mapping each value of the source
+// column to the attribution it requires. A missing file yields no mapping.
+func LoadSourceAttribution(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("No source attribution found at %s", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading source attribution: %w", err)
+	}
+
+	var sourceAttribution map[string]string
+	if err := json.Unmarshal(contents, &sourceAttribution); err != nil {
+		return nil, fmt.Errorf("error parsing source attribution: %w", err)
+	}
+
+	log.Printf("Loaded attribution for %d sources from %s", len(sourceAttribution), path)
+	return sourceAttribution, nil
+}
+
+// DatasetAttribution returns a function giving the attribution for the
+// sources in the current reference data, as listed by ref-data.
+func DatasetAttribution(summaries *SummaryCache, sourceAttribution map[string]string) func() []string {
+	return func() []string {
+		summary, _ := summaries.Get()
+		return resolveAttribution(summary.Sources, sourceAttribution)
+	}
+}
+
+// resolveAttribution returns the static attribution followed by the credit
+// for each distinct source present in the data. With no mapping configured
+// only the static list is returned.
+func resolveAttribution(sources map[string]int, sourceAttribution map[string]string) []string {
+	if len(sourceAttribution) == 0 {
+		return ATTRIBUTION
+	}
+
+	names := make([]string, 0, len(sources))
+	for source := range sources {
+		names = append(names, source)
+	}
+	slices.Sort(names)
+
+	attribution := slices.Clone(ATTRIBUTION)
+	for _, source := range names {
+		credit, ok := sourceAttribution[source]
+		if !ok {
+			log.Printf("WARNING: no attribution configured for source: %s", source)
+			continue
+		}
+		if !slices.Contains(attribution, credit) {
+			attribution = append(attribution, credit)
+		}
+	}
+
+	return attribution
+}
+
+func retrieveSources(db *sql.DB) (map[string]int, error) {
+	rows, err := db.Query("SELECT " + column("source") + ", COUNT(*) FROM " + table() + " GROUP BY " + column("source"))
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	sources := make(map[string]int)
+	var source sql.NullString
+	var count int
+	for rows.Next() {
+		if err := rows.Scan(&source, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if source.Valid {
+			sources[source.String] = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"database/sql"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+
+	defaultHighlightStart = "<mark>"
+	defaultHighlightEnd   = "</mark>"
+)
+
+type Suggestion struct {
+	Fid  int    `json:"fid"`
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Highlight is Name with the part matching q wrapped in the highlight
+	// delimiters, with ?highlight=true; see highlighter.
+	Highlight *string    `json:"highlight,omitempty"`
+	Category  *string    `json:"category,omitempty"`
+	Lat       Coordinate `json:"lat"`
+	Long      Coordinate `json:"long"`
+}
+
+type AutocompleteResponse struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	// MinLength is set when the query was too short to search.
+	MinLength int `json:"min_length,omitempty"`
+}
+
+// Autocomplete suggests POIs whose name starts with q, optionally within a
+// bbox. Queries shorter than minLength characters, which would match almost
+// everything, are not run: they get no suggestions and a min_length hint.
+// With ?highlight=true, each suggestion's name is also given with the match
+// wrapped in highlight_start and highlight_end, by default <mark> tags.
+func Autocomplete(db *sql.DB, minLength int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		q := strings.TrimSpace(c.Query("q"))
+		var bbox []float64
+		if value := c.Query("bbox"); value != "" {
+			var err error
+			bbox, err = parseBBox(value)
+			errs.add("bbox", err)
+		}
+		limit, err := parseLimit("limit", c.Query("limit"))
+		errs.add("limit", err)
+		highlight, err := parseHighlighter(c)
+		errs.add("highlight", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		if utf8.RuneCountInString(q) < minLength {
+			c.JSON(http.StatusOK, AutocompleteResponse{Suggestions: []Suggestion{}, MinLength: minLength})
+			return
+		}
+
+		if limit == 0 {
+			limit = defaultSuggestions
+		}
+		limit = min(limit, maxSuggestions)
+
+		where, arg := likePrefix(column("primary_name"), q)
+		where += " AND " + hasCoordinates()
+		args := []any{arg}
+		if bbox != nil {
+			where += " AND " + bboxClause()
+			args = append(args, bboxArgs(bbox)...)
+		}
+
+		rows, err := db.QueryContext(c.Request.Context(),
+			"SELECT "+columnList("fid", "id", "primary_name", "main_category", "lat", "long")+" FROM "+table()+
+				" WHERE "+where+" ORDER BY "+column("primary_name")+", "+column("fid")+" LIMIT ?",
+			append(args, limit)...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		suggestions := make([]Suggestion, 0)
+		for rows.Next() {
+			var s Suggestion
+			if err := rows.Scan(&s.Fid, &s.Id, &s.Name, &s.Category, &s.Lat, &s.Long); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			if highlight != nil {
+				s.Highlight = highlight.match(s.Name, q)
+			}
+			suggestions = append(suggestions, s)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, AutocompleteResponse{Suggestions: suggestions})
+	}
+}
+
+// highlighter wraps the part of a name matching q in start and end. With the
+// default <mark> delimiters the result is HTML, so the rest of the name is
+// escaped to be safe to insert as markup; custom delimiters give raw text,
+// escaped by whatever renders them.
+type highlighter struct {
+	start, end string
+	escape     bool
+}
+
+// parseHighlighter parses ?highlight, with its highlight_start and
+// highlight_end delimiters; it returns nil unless highlight=true.
+func parseHighlighter(c *gin.Context) (*highlighter, error) {
+	highlight, err := parseBool("highlight", c.Query("highlight"))
+	if err != nil || !highlight {
+		return nil, err
+	}
+
+	start := c.DefaultQuery("highlight_start", defaultHighlightStart)
+	end := c.DefaultQuery("highlight_end", defaultHighlightEnd)
+	return &highlighter{start: start, end: end, escape: start == defaultHighlightStart && end == defaultHighlightEnd}, nil
+}
+
+func (h *highlighter) match(name, q string) *string {
+	return highlightMatch(name, q, h.start, h.end, h.escape)
+}
+
+// highlightMatch returns name with the first case-insensitive match of q
+// wrapped in start and end, or nil if q isn't found. Matching is by rune
+// rather than on lower-cased copies, whose byte offsets can differ from the
+// name's. With escape, the name's text (but not start and end) is
+// HTML-escaped.
+func highlightMatch(name, q, start, end string, escape bool) *string {
+	text := func(s string) string { return s }
+	if escape {
+		text = html.EscapeString
+	}
+
+	n := utf8.RuneCountInString(q)
+	for i := range name {
+		j := i
+		for k := 0; k < n && j < len(name); k++ {
+			_, size := utf8.DecodeRuneInString(name[j:])
+			j += size
+		}
+		if strings.EqualFold(name[i:j], q) {
+			highlighted := text(name[:i]) + start + text(name[i:j]) + end + text(name[j:])
+			return &highlighted
+		}
+	}
+	return nil
+}
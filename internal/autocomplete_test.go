@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHighlightMatch(t *testing.T) {
+	tests := []struct {
+		name, q    string
+		start, end string
+		escape     bool
+		want       *string
+	}{
+		{"The Crown Posada", "the c", "<mark>", "</mark>", true, ptr("<mark>The C</mark>rown Posada")},
+		{"The Crown Posada", "posada", "[", "]", false, ptr("The Crown [Posada]")},
+		{"Fish & <Chips>", "fish", "<mark>", "</mark>", true, ptr("<mark>Fish</mark> &amp; &lt;Chips&gt;")},
+		{"Fish & <Chips>", "& <c", "<mark>", "</mark>", true, ptr("Fish <mark>&amp; &lt;C</mark>hips&gt;")},
+		{"Fish & <Chips>", "fish", "<b>", "</b>", false, ptr("<b>Fish</b> & <Chips>")},
+		{"ÉCOLE Café", "café", "<mark>", "</mark>", true, ptr("ÉCOLE <mark>Café</mark>")},
+		{"The Crown Posada", "tavern", "<mark>", "</mark>", true, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name+"/"+tc.q, func(t *testing.T) {
+			got := highlightMatch(tc.name, tc.q, tc.start, tc.end, tc.escape)
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Errorf("highlightMatch = %v, want %v", deref(got), deref(tc.want))
+			}
+		})
+	}
+}
+
+func TestHighlightEscaping(t *testing.T) {
+	db := newTestDB(t)
+	name := "Fish & <Chips>"
+	err := testutil.Seed(db, []testutil.Fixture{{
+		Id: "08f194ad32c2a009", PrimaryName: &name, Source: "test", SourceRecordId: "9", Lat: 54.97, Long: -1.61,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/autocomplete", Autocomplete(db, 3))
+	r.GET("/search", Search(db, SearchConfig{}))
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"highlight=true", "<mark>Fish</mark> &amp; &lt;Chips&gt;"},
+		{"highlight=true&highlight_start=<b>&highlight_end=</b>", "<b>Fish</b> & <Chips>"},
+	}
+
+	for _, tc := range tests {
+		params := "q=fish&" + url.PathEscape(tc.query)
+		t.Run("autocomplete?"+tc.query, func(t *testing.T) {
+			var resp AutocompleteResponse
+			getJSON(t, r, "/autocomplete?"+params, &resp)
+			if len(resp.Suggestions) != 1 || deref(resp.Suggestions[0].Highlight) != tc.want {
+				t.Errorf("suggestions %+v, want one highlighted %q", resp.Suggestions, tc.want)
+			}
+		})
+		t.Run("search?"+tc.query, func(t *testing.T) {
+			var resp SearchResponse
+			getJSON(t, r, "/search?bbox="+fixturesBBox+"&"+params, &resp)
+			if len(resp.Results) != 1 || deref(resp.Results[0].Highlight) != tc.want {
+				t.Errorf("results %+v, want one highlighted %q", resp.Results, tc.want)
+			}
+		})
+	}
+}
+
+func getJSON(t *testing.T, r *gin.Engine, target string, v any) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected status %d, got %d: %s", target, http.StatusOK, w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"geods-poi-api/internal/geo"
+	"log"
+	"math"
+)
+
+// retrieveBounds returns the dataset extent recorded in gpkg_contents as
+// [LEFT, BOTTOM, RIGHT, TOP] in WGS84, or nil if no extent has been recorded.
+// The extent is in the table's own SRS, so a British National Grid extent is
+// reprojected; one in any other SRS can't be compared with a bbox, so is
+// treated as unknown.
+func retrieveBounds(db *sql.DB) ([]float64, error) {
+	var minX, minY, maxX, maxY sql.NullFloat64
+	var srsID sql.NullInt64
+	err := db.QueryRow(`SELECT min_x, min_y, max_x, max_y, srs_id FROM gpkg_contents WHERE table_name = ?`, mapping.Table).
+		Scan(&minX, &minY, &maxX, &maxY, &srsID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving bounds: %w", err)
+	}
+
+	if !minX.Valid || !minY.Valid || !maxX.Valid || !maxY.Valid {
+		return nil, nil
+	}
+
+	log.Printf("Dataset bounds in db: %f,%f,%f,%f", minX.Float64, minY.Float64, maxX.Float64, maxY.Float64)
+	switch {
+	case !srsID.Valid || srsID.Int64 == sridWGS84:
+		return []float64{minX.Float64, minY.Float64, maxX.Float64, maxY.Float64}, nil
+	case srsID.Int64 == sridOSGB:
+		return reprojectBounds(minX.Float64, minY.Float64, maxX.Float64, maxY.Float64), nil
+	default:
+		log.Printf("WARNING: dataset bounds are in unsupported SRS %d, ignoring them", srsID.Int64)
+		return nil, nil
+	}
+}
+
+// reprojectBounds returns the WGS84 box enclosing the corners of a British
+// National Grid extent. Grid lines aren't parallel to meridians, so every
+// corner is needed.
+func reprojectBounds(minE, minN, maxE, maxN float64) []float64 {
+	bounds := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, corner := range [][2]float64{{minE, minN}, {minE, maxN}, {maxE, minN}, {maxE, maxN}} {
+		lat, long := geo.OSGridToWGS84(corner[0], corner[1])
+		bounds[LEFT], bounds[RIGHT] = min(bounds[LEFT], long), max(bounds[RIGHT], long)
+		bounds[BOTTOM], bounds[TOP] = min(bounds[BOTTOM], lat), max(bounds[TOP], lat)
+	}
+	return bounds
+}
+
+// clampBBox intersects bbox with bounds. It reports whether bbox had to be
+// clamped, and whether the two overlap at all.
+func clampBBox(bbox []float64, bounds []float64) (clamped []float64, wasClamped bool, overlaps bool) {
+	if bounds == nil {
+		return bbox, false, true
+	}
+
+	clamped = []float64{
+		max(bbox[LEFT], bounds[LEFT]),
+		max(bbox[BOTTOM], bounds[BOTTOM]),
+		min(bbox[RIGHT], bounds[RIGHT]),
+		min(bbox[TOP], bounds[TOP]),
+	}
+
+	if clamped[LEFT] > clamped[RIGHT] || clamped[BOTTOM] > clamped[TOP] {
+		return bbox, true, false
+	}
+
+	for i := range bbox {
+		if clamped[i] != bbox[i] {
+			wasClamped = true
+		}
+	}
+
+	return clamped, wasClamped, true
+}
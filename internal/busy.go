@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyRetryDelay is the wait before the first retry of a query that found
+// the database busy, doubling with each further retry.
+const busyRetryDelay = 50 * time.Millisecond
+
+// isBusy reports whether err is SQLite finding the database busy or locked,
+// which is transient: it passes once the connection holding the lock is done.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs fn, retrying it up to retries times, with backoff, for as
+// long as it finds the database busy.
+func retryBusy(ctx context.Context, retries int, fn func() error) error {
+	delay := busyRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !isBusy(err) || attempt > retries {
+			return err
+		}
+
+		log.Printf("database busy (attempt %d of %d, retrying in %s)", attempt, retries+1, delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// busyRetryDriver opens connections whose queries are retried when they find
+// the database busy, beyond SQLite's own busy_timeout wait, so transient
+// contention isn't reported to clients as an error.
+type busyRetryDriver struct {
+	*sqlite3.SQLiteDriver
+	retries int
+}
+
+func (d *busyRetryDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &busyRetryConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), retries: d.retries}, nil
+}
+
+type busyRetryConn struct {
+	*sqlite3.SQLiteConn
+	retries int
+}
+
+func (c *busyRetryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := retryBusy(ctx, c.retries, func() error {
+		var err error
+		rows, err = c.SQLiteConn.QueryContext(ctx, query, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &busyRetryRows{SQLiteRows: rows.(*sqlite3.SQLiteRows), ctx: ctx, retries: c.retries}, nil
+}
+
+// busyRetryRows retries reading the first row, as SQLite only acquires its
+// read lock, and so finds the database busy, when a query is first stepped.
+// Once a row has been returned the query can't be restarted without
+// repeating it, so later errors are returned as they are.
+type busyRetryRows struct {
+	*sqlite3.SQLiteRows
+	ctx     context.Context
+	retries int
+	started bool
+}
+
+func (r *busyRetryRows) Next(dest []driver.Value) error {
+	if r.started {
+		return r.SQLiteRows.Next(dest)
+	}
+
+	r.started = true
+	return retryBusy(r.ctx, r.retries, func() error {
+		return r.SQLiteRows.Next(dest)
+	})
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"geods-poi-api/internal/testutil"
+)
+
+func init() {
+	sql.Register("sqlite3_busy_retry_test", newSQLiteDriver(nil, 5))
+	sql.Register("sqlite3_busy_no_retry_test", newSQLiteDriver(nil, 0))
+}
+
+// lockedDB opens a shared-cache fixture database and takes a lock on it with
+// an open transaction running lock, which is committed after hold. Other
+// connections to a shared cache are refused with SQLITE_LOCKED rather than
+// waiting, so only retrying gets them past it.
+func lockedDB(t *testing.T, driverName string, lock string, hold time.Duration) *sql.DB {
+	t.Helper()
+
+	db, err := testutil.Open(driverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Opening a connection reads the schema, so the one the query will use
+	// is opened, and returned to the pool, before the lock is taken.
+	idle, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idle.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, lock); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	t.Cleanup(func() { <-done })
+	time.AfterFunc(hold, func() {
+		defer close(done)
+		if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+			t.Errorf("error committing lock: %v", err)
+		}
+		_ = conn.Close()
+	})
+	return db
+}
+
+func TestBusyRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		// lock is run in the locking transaction.
+		lock string
+	}{
+		// An uncommitted schema change makes preparing any statement, and
+		// so QueryContext, fail.
+		{"on query", "CREATE TABLE lock (id INTEGER)"},
+		// An uncommitted write to the table only fails the query once it's
+		// stepped, on the first Next.
+		{"on first row", "UPDATE poi_uk SET primary_name = primary_name WHERE fid = 1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Run("retried", func(t *testing.T) {
+				db := lockedDB(t, "sqlite3_busy_retry_test", tc.lock, 2*busyRetryDelay)
+
+				var count int
+				if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM poi_uk").Scan(&count); err != nil {
+					t.Fatalf("expected the query to be retried until the lock was released, got %v", err)
+				}
+				if count != len(testutil.Fixtures) {
+					t.Errorf("expected %d rows, got %d", len(testutil.Fixtures), count)
+				}
+			})
+
+			t.Run("not retried", func(t *testing.T) {
+				db := lockedDB(t, "sqlite3_busy_no_retry_test", tc.lock, 2*busyRetryDelay)
+
+				var count int
+				err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM poi_uk").Scan(&count)
+				if !isBusy(err) {
+					t.Fatalf("expected the locked database to be reported busy, got %v", err)
+				}
+			})
+		})
+	}
+}
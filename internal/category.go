@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kofalt/go-memoize"
+)
+
+type CategoryResponse struct {
+	Category string `json:"category"`
+	Label    string `json:"label"`
+	Count    int    `json:"count"`
+	// IconURL is omitted for categories without a marker icon.
+	IconURL string `json:"icon_url,omitempty"`
+	// Image is as returned by the image endpoint, or null if it couldn't be
+	// fetched.
+	Image gin.H `json:"image"`
+	// Errors lists the parts of the bundle that couldn't be loaded.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Category bundles what a category detail panel shows, its label, POI count,
+// marker icon and image, into one call. Should Unsplash fail, the rest is
+// still returned, with the failure listed in errors. The image takes the
+// image endpoint's orientation and size parameters.
+func Category(summaries *SummaryCache, labels Labels, cache *memoize.Memoizer, queries map[string]string, fallbackURL string) gin.HandlerFunc {
+	localizer := newLocalizer(labels)
+
+	return func(c *gin.Context) {
+		category := c.Param("category")
+		var errs paramErrors
+		orientation, err := parseOrientation(c.Query("orientation"))
+		errs.add("orientation", err)
+		size, err := parseSize(c.Query("size"))
+		errs.add("size", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		summary, _ := summaries.Get()
+		count, counted := summary.Categories[category]
+		icon, mapped := currentIcons()[category]
+		if !counted && !mapped {
+			c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+			return
+		}
+
+		resp := CategoryResponse{
+			Category: category,
+			Label:    localizer.label(localizer.lookup(c.GetHeader("Accept-Language")), category),
+			Count:    count,
+		}
+		if icon != "" {
+			resp.IconURL = markerURL(category)
+		}
+
+		photos, err := searchPhotos(c.Request.Context(), cache, queries, category, orientation)
+		switch {
+		case err != nil:
+			log.Printf("Error fetching image: %v", err)
+			resp.Errors = append(resp.Errors, "image unavailable")
+		case len(photos.Results) == 0:
+			resp.Image = imageResponse(nil, nil, category, fallbackURL)
+		default:
+			resp.Image = imageResponse(&photos.Results[0], size, category, fallbackURL)
+		}
+
+		addVary(c, "Accept-Language")
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultChangesPageSize is the page size for changes when no limit is given.
+const defaultChangesPageSize = 1000
+
+type ChangesResponse struct {
+	Results []POI `json:"results"`
+	// NextCursor fetches the next page; it is omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// HighWaterMark is the latest updated_at in the dataset when the request
+	// was made, in RFC 3339: pass it as since once every page has been read.
+	HighWaterMark *string  `json:"high_water_mark"`
+	Attribution   []string `json:"attribution"`
+}
+
+// changesCursor is the keyset position of the last POI on a page: results
+// are ordered by updated_at, then fid.
+type changesCursor struct {
+	UpdatedAt string `json:"t"`
+	Fid       int    `json:"f"`
+}
+
+func (cur changesCursor) encode() string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseChangesCursor(value string) (*changesCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cur changesCursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cur, nil
+}
+
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("since is required")
+	}
+	since, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be an RFC 3339 timestamp, e.g. 2025-05-01T10:00:00Z")
+	}
+	return since, nil
+}
+
+// Changes returns the POIs updated after a timestamp, oldest first, paged
+// with a keyset cursor, for clients keeping a local copy in sync. It needs
+// an updated_at column; datasets without one get a 501, and clients should
+// fall back to a full download whenever ref-data's last_updated changes.
+func Changes(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasColumn("updated_at") {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "this dataset does not record when POIs change"})
+			return
+		}
+
+		var errs paramErrors
+		since, err := parseSince(c.Query("since"))
+		errs.add("since", err)
+		cursor, err := parseChangesCursor(c.Query("cursor"))
+		errs.add("cursor", err)
+		limit, err := parseLimit("limit", c.Query("limit"))
+		errs.add("limit", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+		if limit == 0 {
+			limit = defaultChangesPageSize
+		}
+
+		// Timestamps are compared with julianday() as writers differ in how
+		// they format them.
+		updatedAt := "julianday(" + column("updated_at") + ")"
+
+		// The high-water mark is reformatted as RFC 3339, in UTC, so that it
+		// can be passed back as since whatever format it was stored in.
+		var highWaterMark *string
+		err = db.QueryRowContext(c.Request.Context(),
+			"SELECT strftime('%Y-%m-%dT%H:%M:%fZ', "+column("updated_at")+") FROM "+table()+" ORDER BY "+updatedAt+" DESC LIMIT 1",
+		).Scan(&highWaterMark)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("error retrieving high water mark: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		where := updatedAt + " > julianday(?) AND " + hasCoordinates()
+		args := []any{since.UTC().Format(time.RFC3339Nano)}
+		if cursor != nil {
+			where += " AND (" + updatedAt + " > julianday(?) OR (" + updatedAt + " = julianday(?) AND " + column("fid") + " > ?))"
+			args = append(args, cursor.UpdatedAt, cursor.UpdatedAt, cursor.Fid)
+		}
+
+		// One extra row is fetched to tell whether there's another page.
+		rows, err := db.QueryContext(c.Request.Context(),
+			"SELECT "+columnList(selectedColumns()...)+" FROM "+table()+" WHERE "+where+
+				" ORDER BY "+updatedAt+", "+column("fid")+" LIMIT ?",
+			append(args, limit+1)...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		results := make([]POI, 0)
+		for rows.Next() {
+			poi, err := scanPOI(rows, scanOptions{})
+			if err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			results = append(results, poi)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		resp := ChangesResponse{
+			Results:       results,
+			HighWaterMark: highWaterMark,
+			Attribution:   ATTRIBUTION,
+		}
+		if len(results) > limit {
+			resp.Results = results[:limit]
+			last := resp.Results[limit-1]
+			resp.NextCursor = changesCursor{UpdatedAt: *last.UpdatedAt, Fid: last.Fid}.encode()
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
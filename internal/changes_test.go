@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChanges(t *testing.T) {
+	db := newTestDB(t)
+
+	// Writers format updated_at differently; POIs 2, 3 and 6 changed at the
+	// same instant.
+	updates := map[int]string{
+		1: "2025-05-01 10:00:00",
+		2: "2025-04-01T09:00:00Z",
+		3: "2025-04-01T09:00:00Z",
+		4: "2025-03-01T08:00:00.500+01:00",
+		5: "2025-04-15",
+		6: "2025-04-01 09:00:00",
+		7: "2025-02-01T00:00:00Z",
+		8: "2024-01-01T00:00:00Z",
+	}
+	if _, err := db.Exec(`ALTER TABLE poi_uk ADD COLUMN updated_at TEXT`); err != nil {
+		t.Fatal(err)
+	}
+	for fid, updatedAt := range updates {
+		if _, err := db.Exec(`UPDATE poi_uk SET updated_at = ? WHERE fid = ?`, updatedAt, fid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := presentColumns
+	t.Cleanup(func() { presentColumns = previous })
+	if err := DetectOptionalColumns(db); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/changes", Changes(db))
+
+	var got []int
+	var highWaterMark *string
+	query := url.Values{"since": {"2025-01-01T00:00:00Z"}, "limit": {"2"}}
+	for pages := 1; ; pages++ {
+		var resp ChangesResponse
+		getJSON(t, r, "/changes?"+query.Encode(), &resp)
+		if len(resp.Results) > 2 {
+			t.Fatalf("page %d has %d results, want at most 2", pages, len(resp.Results))
+		}
+		got = append(got, fids(resp.Results)...)
+		highWaterMark = resp.HighWaterMark
+		if resp.NextCursor == "" {
+			break
+		}
+		if pages > len(updates) {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		query.Set("cursor", resp.NextCursor)
+	}
+	if want := []int{7, 4, 2, 3, 6, 5, 1}; !slices.Equal(got, want) {
+		t.Errorf("got fids %v, want %v", got, want)
+	}
+
+	if highWaterMark == nil || *highWaterMark != "2025-05-01T10:00:00.000Z" {
+		t.Fatalf("high_water_mark = %q, want 2025-05-01T10:00:00.000Z", deref(highWaterMark))
+	}
+	var resp ChangesResponse
+	getJSON(t, r, "/changes?since="+url.QueryEscape(*highWaterMark), &resp)
+	if len(resp.Results) != 0 || resp.NextCursor != "" {
+		t.Errorf("since the high-water mark got fids %v, cursor %q, want none", fids(resp.Results), resp.NextCursor)
+	}
+
+	getJSON(t, r, "/changes?since=2025-04-01T09:00:00Z", &resp)
+	if got, want := fids(resp.Results), []int{5, 1}; !slices.Equal(got, want) {
+		t.Errorf("since 2025-04-01T09:00:00Z got fids %v, want %v", got, want)
+	}
+}
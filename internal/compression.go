@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uncompressedRoutes are served without the compression middleware: their
+// bodies are already compressed images or archives, where gzipping again just
+// burns CPU, or event streams that must flush as they go, which buffering in
+// the middleware defeats.
+var uncompressedRoutes = []string{
+	"/v1/geods-poi/marker/shadow",
+	"/v1/geods-poi/marker/:category",
+	"/v1/geods-poi/markers/all.zip",
+	"/v1/geods-poi/image/:category/raw",
+	"/v1/geods-poi/search/stream",
+}
+
+// ExcludeFromCompression returns an exclusion func for the compression
+// middleware that skips uncompressedRoutes plus any extra route patterns
+// (as registered, e.g. "/v1/geods-poi/marker/:category").
+func ExcludeFromCompression(extra []string) func(c *gin.Context) bool {
+	excluded := make(map[string]struct{}, len(uncompressedRoutes)+len(extra))
+	for _, route := range slices.Concat(uncompressedRoutes, extra) {
+		excluded[route] = struct{}{}
+	}
+
+	return func(c *gin.Context) bool {
+		_, ok := excluded[c.FullPath()]
+		return ok
+	}
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeoPackageContent is a gpkg_contents row, describing one table in the
+// GeoPackage.
+type GeoPackageContent struct {
+	TableName   string   `json:"table_name"`
+	DataType    string   `json:"data_type"`
+	Identifier  *string  `json:"identifier"`
+	Description *string  `json:"description"`
+	LastChange  string   `json:"last_change"`
+	MinX        *float64 `json:"min_x"`
+	MinY        *float64 `json:"min_y"`
+	MaxX        *float64 `json:"max_x"`
+	MaxY        *float64 `json:"max_y"`
+	SrsId       *int     `json:"srs_id"`
+}
+
+type GeoPackageContentsResponse struct {
+	Contents []GeoPackageContent `json:"contents"`
+}
+
+// GeoPackageContents returns the GeoPackage's own metadata from
+// gpkg_contents, as given, for clients that want provenance. Every row is
+// listed, the POI table first.
+func GeoPackageContents(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// last_change is cast so the driver doesn't reformat it as a
+		// DATETIME column.
+		rows, err := db.QueryContext(c.Request.Context(),
+			`SELECT table_name, data_type, identifier, description, CAST(last_change AS TEXT), min_x, min_y, max_x, max_y, srs_id
+			FROM gpkg_contents ORDER BY table_name != ?, table_name`,
+			mapping.Table,
+		)
+		if err != nil {
+			log.Printf("error querying gpkg_contents: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		contents := make([]GeoPackageContent, 0)
+		for rows.Next() {
+			content, err := scanGeoPackageContent(rows)
+			if err != nil {
+				log.Printf("error scanning gpkg_contents: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			contents = append(contents, *content)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GeoPackageContentsResponse{Contents: contents})
+	}
+}
+
+func scanGeoPackageContent(rows *sql.Rows) (*GeoPackageContent, error) {
+	var content GeoPackageContent
+	var dataType, identifier, description, lastChange sql.NullString
+	var minX, minY, maxX, maxY sql.NullFloat64
+	var srsId sql.NullInt64
+
+	if err := rows.Scan(&content.TableName, &dataType, &identifier, &description, &lastChange,
+		&minX, &minY, &maxX, &maxY, &srsId); err != nil {
+		return nil, fmt.Errorf("error scanning row: %w", err)
+	}
+
+	content.DataType = dataType.String
+	content.LastChange = lastChange.String
+	if identifier.Valid {
+		content.Identifier = &identifier.String
+	}
+	if description.Valid {
+		content.Description = &description.String
+	}
+	if minX.Valid {
+		content.MinX = &minX.Float64
+	}
+	if minY.Valid {
+		content.MinY = &minY.Float64
+	}
+	if maxX.Valid {
+		content.MaxX = &maxX.Float64
+	}
+	if maxY.Valid {
+		content.MaxY = &maxY.Float64
+	}
+	if srsId.Valid {
+		id := int(srsId.Int64)
+		content.SrsId = &id
+	}
+
+	return &content, nil
+}
@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"strconv"
+)
+
+// coordinatePrecision is the number of decimal places coordinates are written
+// with; -1 uses the fewest digits that represent the value exactly.
+var coordinatePrecision = -1
+
+// SetCoordinatePrecision sets the number of decimal places used when writing
+// coordinates as JSON, or -1 for the shortest exact representation.
+func SetCoordinatePrecision(precision int) {
+	coordinatePrecision = precision
+}
+
+// Coordinate is a latitude, longitude, easting or northing. It always
+// marshals to JSON in fixed-point notation, never as an exponent such as
+// 1e-05, which some strict GeoJSON parsers reject.
+type Coordinate float64
+
+func (c Coordinate) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, float64(c), 'f', coordinatePrecision, 64), nil
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCoordinateMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		coordinate Coordinate
+		precision  int
+		want       string
+	}{
+		// encoding/json writes these three as exponents.
+		{"small", 0.0000001, -1, "0.0000001"},
+		{"small negative", -0.00000012, -1, "-0.00000012"},
+		{"large", 1e21, -1, "1000000000000000000000"},
+		{"zero", 0, -1, "0"},
+		{"ordinary", -1.6178, -1, "-1.6178"},
+		{"rounded", 54.9783456, 4, "54.9783"},
+		{"small rounded", 0.0000001, 6, "0.000000"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			previous := coordinatePrecision
+			t.Cleanup(func() { SetCoordinatePrecision(previous) })
+			SetCoordinatePrecision(tc.precision)
+
+			got, err := json.Marshal(struct {
+				Lat Coordinate `json:"lat"`
+			}{tc.coordinate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `{"lat":` + tc.want + `}`; string(got) != want {
+				t.Errorf("marshalled %g as %s, want %s", float64(tc.coordinate), got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"geods-poi-api/internal/geo"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+	"github.com/twpayne/go-geom/encoding/wkt"
+)
+
+const (
+	maxCorridorLength = 250_000 // metres
+	maxCorridorBuffer = 5_000   // metres
+)
+
+type CorridorRequest struct {
+	// Line is either a WKT string or a GeoJSON LineString geometry, in WGS84.
+	Line json.RawMessage `json:"line"`
+	// Buffer is the distance in metres either side of the line to search.
+	Buffer     float64  `json:"buffer"`
+	Categories []string `json:"categories"`
+}
+
+// SearchCorridor returns POIs within a buffered corridor around a line. The
+// line's buffered bbox pre-filters in SQL before an exact distance-to-segment
+// test in Go.
+func SearchCorridor(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CorridorRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+
+		line, err := parseLineString(req.Line)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Buffer <= 0 || req.Buffer > maxCorridorBuffer {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("buffer must be between 0 and %d metres", maxCorridorBuffer)})
+			return
+		}
+
+		if length := lineLength(line); length > maxCorridorLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line is %.0f metres long, exceeding the maximum of %d", length, maxCorridorLength)})
+			return
+		}
+
+		categories, err := parseCategories(strings.Join(req.Categories, ","))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(
+			"SELECT "+columnList(selectedColumns()...)+" FROM "+table()+" WHERE "+bboxClause()+" ORDER BY "+column("fid"),
+			bboxArgs(bufferedBBox(line, req.Buffer))...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		results := make([]POI, 0)
+		for rows.Next() {
+			poi, err := scanPOI(rows, scanOptions{})
+			if err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+
+			if len(categories) > 0 && !hasCategoryMatch(poi.Categories, categories) {
+				continue
+			}
+
+			if distanceToLine(float64(poi.Lat), float64(poi.Long), line) <= req.Buffer {
+				results = append(results, poi)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, SearchResponse{
+			Results:     results,
+			Attribution: ATTRIBUTION,
+		})
+	}
+}
+
+func parseLineString(raw json.RawMessage) (*geom.LineString, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("line is required")
+	}
+
+	var g geom.T
+	var wktString string
+	if err := json.Unmarshal(raw, &wktString); err == nil {
+		if g, err = wkt.Unmarshal(wktString); err != nil {
+			return nil, fmt.Errorf("invalid WKT line: %w", err)
+		}
+	} else if err := geojson.Unmarshal(raw, &g); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON line: %w", err)
+	}
+
+	line, ok := g.(*geom.LineString)
+	if !ok {
+		return nil, fmt.Errorf("line must be a LineString, not a %T", g)
+	}
+	if line.NumCoords() < 2 {
+		return nil, fmt.Errorf("line must have at least 2 points")
+	}
+
+	return line, nil
+}
+
+func lineLength(line *geom.LineString) float64 {
+	length := 0.0
+	for i := 1; i < line.NumCoords(); i++ {
+		a, b := line.Coord(i-1), line.Coord(i)
+		length += geo.Haversine(a.Y(), a.X(), b.Y(), b.X())
+	}
+	return length
+}
+
+// bufferedBBox returns the line's bbox grown by buffer metres on every side.
+func bufferedBBox(line *geom.LineString, buffer float64) []float64 {
+	bounds := line.Bounds()
+	maxAbsLat := math.Max(math.Abs(bounds.Min(1)), math.Abs(bounds.Max(1)))
+
+	dLat := buffer / geo.MetresPerDegree
+	dLong := buffer / (geo.MetresPerDegree * math.Max(math.Cos(maxAbsLat*math.Pi/180), 1e-6))
+
+	return []float64{bounds.Min(0) - dLong, bounds.Min(1) - dLat, bounds.Max(0) + dLong, bounds.Max(1) + dLat}
+}
+
+func distanceToLine(lat, long float64, line *geom.LineString) float64 {
+	nearest := math.Inf(1)
+	for i := 1; i < line.NumCoords(); i++ {
+		a, b := line.Coord(i-1), line.Coord(i)
+		nearest = math.Min(nearest, geo.DistanceToSegment(lat, long, a.Y(), a.X(), b.Y(), b.X()))
+	}
+	return nearest
+}
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CoverageResponse struct {
+	Resolution  int            `json:"resolution"`
+	Cells       map[string]int `json:"cells"`
+	Attribution []string       `json:"attribution"`
+}
+
+// Coverage counts the POIs (optionally restricted to some categories) within
+// a bbox, bucketed by their H3 cell at the requested resolution.
+func Coverage(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bbox, err := parseBBox(c.Query("bbox"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		resolution, err := parseResolution(c.Query("resolution"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		categories, err := parseCategories(c.Query("category"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(c.Request.Context(),
+			"SELECT "+columnList("h3_15", "main_category", "alternate_category")+" FROM "+table()+" WHERE "+bboxClause(),
+			bboxArgs(bbox)...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		cells := make(map[string]int)
+		var h3 string
+		var mainCategory sql.NullString
+		var alternateCategory sql.NullString
+
+		for rows.Next() {
+			if err := rows.Scan(&h3, &mainCategory, &alternateCategory); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+
+			if len(categories) > 0 && !hasCategoryMatch(splitCategories(mainCategory, alternateCategory), categories) {
+				continue
+			}
+
+			cell, err := h3Parent(h3, resolution)
+			if err != nil {
+				log.Printf("skipping POI with bad h3_15 value: %v", err)
+				continue
+			}
+			cells[cell]++
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, CoverageResponse{
+			Resolution:  resolution,
+			Cells:       cells,
+			Attribution: ATTRIBUTION,
+		})
+	}
+}
+
+// maxH3Cells caps the cells H3Cover and H3OccupiedCells list; a bbox needing
+// more should be listed at a coarser resolution.
+const maxH3Cells = 10000
+
+type H3CoverResponse struct {
+	Resolution int      `json:"resolution"`
+	Cells      []string `json:"cells"`
+}
+
+// H3Cover lists the H3 cells at a resolution covering a bbox, for clients to
+// fetch a cell at a time with ?h3_parent, which makes each response
+// cacheable on its own. It is a polyfill of the bbox: every cell overlapping
+// it is listed, including those holding no POIs and those straddling its
+// edge, so the cells' POIs include, but may go beyond, the bbox's.
+func H3Cover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		bbox, err := parseBBox(c.Query("bbox"))
+		errs.add("bbox", err)
+		resolution, err := parseResolution(c.Query("resolution"))
+		errs.add("resolution", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		tooMany := func() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bbox is covered by more than %d cells at resolution %d: use a coarser resolution", maxH3Cells, resolution)})
+		}
+
+		// Cell areas vary, so the estimate only turns away a bbox far too big
+		// before its polyfill is allocated; counting the cells catches those
+		// just over the cap.
+		if h3CoverEstimate(bbox, resolution) > 2*maxH3Cells {
+			tooMany()
+			return
+		}
+		cells := h3Cover(bbox, resolution)
+		if len(cells) > maxH3Cells {
+			tooMany()
+			return
+		}
+
+		c.JSON(http.StatusOK, H3CoverResponse{
+			Resolution: resolution,
+			Cells:      cells,
+		})
+	}
+}
+
+type H3OccupiedCellsResponse struct {
+	Resolution int      `json:"resolution"`
+	Cells      []string `json:"cells"`
+}
+
+// H3OccupiedCells lists the H3 cells at a resolution occupied by the POIs
+// within a bbox, for clients to fetch a cell at a time with ?h3_parent, which
+// makes each response cacheable on its own. Unlike H3Cover, the cells are the
+// parents of the POIs' stored h3_15 indexes, so cells holding none of its
+// POIs (which would return no results) are left out.
+func H3OccupiedCells(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		bbox, err := parseBBox(c.Query("bbox"))
+		errs.add("bbox", err)
+		resolution, err := parseResolution(c.Query("resolution"))
+		errs.add("resolution", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		rows, err := db.QueryContext(c.Request.Context(),
+			"SELECT "+column("h3_15")+" FROM "+table()+" WHERE "+bboxClause(),
+			bboxArgs(bbox)...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		cells := make(map[string]struct{})
+		var h3 string
+		for rows.Next() {
+			if err := rows.Scan(&h3); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+
+			cell, err := h3Parent(h3, resolution)
+			if err != nil {
+				log.Printf("skipping POI with bad h3_15 value: %v", err)
+				continue
+			}
+			cells[cell] = struct{}{}
+			if len(cells) > maxH3Cells {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bbox has POIs in more than %d cells at resolution %d: use a coarser resolution", maxH3Cells, resolution)})
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, H3OccupiedCellsResponse{
+			Resolution: resolution,
+			Cells:      slices.Sorted(maps.Keys(cells)),
+		})
+	}
+}
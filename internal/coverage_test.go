@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uber/h3-go"
+)
+
+func TestH3OccupiedCells(t *testing.T) {
+	db := newTestDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/occupied", H3OccupiedCells(db))
+	r.GET("/coverage", Coverage(db))
+
+	for _, resolution := range []int{5, 8, 12} {
+		counts := make(map[string]int)
+		for _, f := range testutil.Fixtures {
+			if f.NoCoordinates {
+				continue
+			}
+			cell, err := h3Parent(f.H3_15, resolution)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[cell]++
+		}
+		want := slices.Sorted(maps.Keys(counts))
+
+		var occupied H3OccupiedCellsResponse
+		getJSON(t, r, "/occupied?bbox="+fixturesBBox+"&resolution="+strconv.Itoa(resolution), &occupied)
+		if occupied.Resolution != resolution || !slices.Equal(occupied.Cells, want) {
+			t.Errorf("resolution %d: occupied cells %v, want %v", resolution, occupied.Cells, want)
+		}
+
+		var coverage CoverageResponse
+		getJSON(t, r, "/coverage?bbox="+fixturesBBox+"&resolution="+strconv.Itoa(resolution), &coverage)
+		if !maps.Equal(coverage.Cells, counts) {
+			t.Errorf("resolution %d: coverage %v, want %v", resolution, coverage.Cells, counts)
+		}
+	}
+}
+
+func TestH3Cover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/cover", H3Cover())
+
+	bbox := []float64{-1.62, 54.96, -1.60, 54.99}
+	const resolution = 9
+
+	var cover H3CoverResponse
+	getJSON(t, r, "/cover?bbox="+fixturesBBox+"&resolution="+strconv.Itoa(resolution), &cover)
+	if cover.Resolution != resolution || !slices.IsSorted(cover.Cells) {
+		t.Fatalf("got resolution %d, cells %v: want resolution %d, cells sorted", cover.Resolution, cover.Cells, resolution)
+	}
+
+	occupied := make(map[string]struct{})
+	for _, f := range testutil.Fixtures {
+		if !f.NoCoordinates {
+			occupied[h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: f.Lat, Longitude: f.Long}, resolution))] = struct{}{}
+		}
+	}
+
+	// Every point in the bbox, POI or not, and on its edges, falls in a
+	// listed cell.
+	for i := 0; i <= 20; i++ {
+		for j := 0; j <= 20; j++ {
+			point := h3.GeoCoord{
+				Latitude:  bbox[BOTTOM] + (bbox[TOP]-bbox[BOTTOM])*float64(i)/20,
+				Longitude: bbox[LEFT] + (bbox[RIGHT]-bbox[LEFT])*float64(j)/20,
+			}
+			if cell := h3.ToString(h3.FromGeo(point, resolution)); !slices.Contains(cover.Cells, cell) {
+				t.Errorf("cell %s of %v is missing from the cover", cell, point)
+			}
+		}
+	}
+
+	empty := 0
+	for _, cell := range cover.Cells {
+		if _, ok := occupied[cell]; !ok {
+			empty++
+		}
+		if !cellIntersectsBBox(h3.ToGeoBoundary(h3.FromString(cell)), bbox) {
+			t.Errorf("cell %s lies outside the bbox", cell)
+		}
+	}
+	if empty == 0 {
+		t.Errorf("got only the cells occupied by POIs: %v", cover.Cells)
+	}
+
+	outside := h3.ToString(h3.FromGeo(h3.GeoCoord{Latitude: 54.95, Longitude: -1.61}, resolution))
+	if slices.Contains(cover.Cells, outside) {
+		t.Errorf("cover includes %s, south of the bbox", outside)
+	}
+}
+
+func TestH3CoverRejects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/cover", H3Cover())
+
+	for _, query := range []string{
+		"bbox=" + fixturesBBox + "&resolution=16",
+		"bbox=" + fixturesBBox + "&resolution=x",
+		"bbox=" + fixturesBBox,
+		"resolution=8",
+		// About 14,000 cells
+		"bbox=" + fixturesBBox + "&resolution=12",
+		"bbox=-8,49,2,61&resolution=15",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cover?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kofalt/go-memoize"
+)
+
+// crosstabFields are the POI fields category counts can be broken down by.
+var crosstabFields = []string{"source", "region", "country"}
+
+type CrosstabResponse struct {
+	By string `json:"by"`
+	// Categories maps each category to its counts by value of the By
+	// field. POIs without a value are counted under "unknown".
+	Categories map[string]map[string]int `json:"categories"`
+}
+
+// Crosstab breaks the ref-data category counts down by a second field, such
+// as ?by=source. Like ref-data, the counts include alternate categories. Each
+// breakdown is computed with a single GROUP BY and cached for ttl, or until
+// the dataset changes.
+func Crosstab(db *sql.DB, ttl time.Duration) gin.HandlerFunc {
+	cache := TrackCache("crosstab", memoize.NewMemoizer(ttl, time.Hour))
+
+	return func(c *gin.Context) {
+		by := c.Query("by")
+		if !slices.Contains(crosstabFields, by) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("by must be one of: %s", strings.Join(crosstabFields, ", "))})
+			return
+		}
+
+		categories, err, _ := memoize.Call(cache, lastChange(c.Request.Context(), db)+"\x00"+by, func() (map[string]map[string]int, error) {
+			return crosstab(db, by)
+		})
+		if err != nil {
+			log.Printf("error computing crosstab: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, CrosstabResponse{By: by, Categories: categories})
+	}
+}
+
+func crosstab(db *sql.DB, by string) (map[string]map[string]int, error) {
+	rows, err := db.Query(
+		"SELECT " + columnList("main_category", "alternate_category") + ", " + column(by) + ", COUNT(*) FROM " + table() +
+			" GROUP BY " + columnList("main_category", "alternate_category") + ", " + column(by),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	categories := make(map[string]map[string]int)
+	var mainCategory, alternateCategory, value sql.NullString
+	var count int
+	for rows.Next() {
+		if err := rows.Scan(&mainCategory, &alternateCategory, &value, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		key := strings.TrimSpace(value.String)
+		if key == "" {
+			key = "unknown"
+		}
+		for _, category := range splitCategories(mainCategory, alternateCategory) {
+			if categories[category] == nil {
+				categories[category] = make(map[string]int)
+			}
+			categories[category][key] += count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCrosstabWithoutGeoPackageContents(t *testing.T) {
+	db := newTestDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/crosstab", Crosstab(db, 0))
+
+	var before CrosstabResponse
+	getJSON(t, r, "/crosstab?by=source", &before)
+	if len(before.Categories) == 0 {
+		t.Fatalf("got no categories: %+v", before)
+	}
+
+	if _, err := db.Exec(`DROP TABLE gpkg_contents`); err != nil {
+		t.Fatal(err)
+	}
+	var after CrosstabResponse
+	getJSON(t, r, "/crosstab?by=source", &after)
+	if !maps.EqualFunc(after.Categories, before.Categories, maps.Equal) {
+		t.Errorf("crosstab without gpkg_contents = %v, want %v", after.Categories, before.Categories)
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"fmt"
+	"geods-poi-api/internal/geo"
+	"strconv"
+	"strings"
+)
+
+// maxDedupeDistance caps ?dedupe, beyond which POIs sharing a name are more
+// likely to be a chain's separate branches than duplicates.
+const maxDedupeDistance = 1000 // metres
+
+// POISource identifies a record merged into a deduplicated POI.
+type POISource struct {
+	Fid            int    `json:"fid"`
+	Source         string `json:"source"`
+	SourceRecordId string `json:"source_record_id"`
+}
+
+func parseDedupe(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	metres, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || !(metres > 0 && metres <= maxDedupeDistance) {
+		return 0, fmt.Errorf("invalid dedupe value '%s': must be a distance between 0 and %d metres", value, maxDedupeDistance)
+	}
+
+	return metres, nil
+}
+
+// dedupePOIs collapses POIs with the same name and main category lying within
+// metres of each other, as when two sources list the same place. Each
+// cluster is represented by its first POI, whose Sources lists every record
+// merged into it, itself included. POIs are clustered greedily in order, each
+// joining the first representative within range; unnamed POIs are never
+// merged, as there's nothing to tell them apart by.
+func dedupePOIs(pois []POI, metres float64) []POI {
+	results := make([]POI, 0, len(pois))
+	// clusters holds the positions in results of the representatives for
+	// each name and main category.
+	clusters := make(map[string][]int)
+
+	for _, poi := range pois {
+		if poi.PrimaryName == nil || strings.TrimSpace(*poi.PrimaryName) == "" {
+			results = append(results, poi)
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(*poi.PrimaryName)) + "\x00" + mainCategory(poi)
+		merged := false
+		for _, i := range clusters[key] {
+			rep := &results[i]
+			if geo.Haversine(float64(rep.Lat), float64(rep.Long), float64(poi.Lat), float64(poi.Long)) <= metres {
+				rep.Sources = append(rep.Sources, poiSource(poi))
+				merged = true
+				break
+			}
+		}
+		if merged {
+			continue
+		}
+
+		poi.Sources = []POISource{poiSource(poi)}
+		clusters[key] = append(clusters[key], len(results))
+		results = append(results, poi)
+	}
+
+	return results
+}
+
+func poiSource(poi POI) POISource {
+	return POISource{Fid: poi.Fid, Source: poi.Source, SourceRecordId: poi.SourceRecordId}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+type UnmappedCategoriesResponse struct {
+	// Unmapped categories are present in the data but have no marker icon.
+	Unmapped []CategoryCount `json:"unmapped"`
+	// Unused categories have a marker icon but never appear in the data.
+	Unused []string `json:"unused"`
+}
+
+// UnmappedCategories cross-references the categories in the data against the
+// marker mappings, to help keep the mappings file complete.
+func UnmappedCategories(summaries *SummaryCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, _ := summaries.Get()
+		icons := currentIcons()
+		unmapped := make([]CategoryCount, 0)
+		for category, count := range summary.Categories {
+			if icons[category] == "" {
+				unmapped = append(unmapped, CategoryCount{Category: category, Count: count})
+			}
+		}
+		slices.SortFunc(unmapped, func(a, b CategoryCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Category, b.Category))
+		})
+
+		unused := make([]string, 0)
+		for category := range icons {
+			if _, exists := summary.Categories[category]; !exists {
+				unused = append(unused, category)
+			}
+		}
+		slices.Sort(unused)
+
+		c.JSON(http.StatusOK, UnmappedCategoriesResponse{
+			Unmapped: unmapped,
+			Unused:   unused,
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kofalt/go-memoize"
+)
+
+// densityCellSize is the side, in degrees, of the cells POIs are counted in
+// to estimate a bbox's results: about 5km north to south in the UK.
+const densityCellSize = 0.05
+
+type EstimateResponse struct {
+	BBox []float64 `json:"bbox"`
+	// Estimate is the approximate number of POIs a search of the bbox (and
+	// categories) would return.
+	Estimate int `json:"estimate"`
+	// CellSize is the side in degrees of the grid the estimate is made on.
+	CellSize    float64  `json:"cell_size"`
+	Attribution []string `json:"attribution"`
+}
+
+// densityCell is the number of POIs in one cell of the density grid, in
+// total and by (lower-cased) category.
+type densityCell struct {
+	row, col   int
+	total      int
+	categories map[string]int
+}
+
+// Estimate approximates how many POIs a search of a bbox would return, for
+// clients to warn before a search that would return too many to be useful.
+//
+// Rather than counting the bbox itself, which costs as much as the search
+// on a large box, it sums a grid of POI counts built with one GROUP BY over
+// the table and cached for ttl, or until the dataset changes. Cells on the
+// bbox's edge count in proportion to how much of their area it covers, as
+// if their POIs were spread evenly. With categories, a cell counts the POIs
+// of each category, capped at its total, so a POI in two of the categories
+// may be counted twice.
+func Estimate(db *sql.DB, ttl time.Duration) gin.HandlerFunc {
+	cache := TrackCache("density", memoize.NewMemoizer(ttl, time.Hour))
+
+	return func(c *gin.Context) {
+		var errs paramErrors
+		bbox, err := parseAreaBBox(c.Query("bbox"))
+		errs.add("bbox", err)
+		categories, err := parseCategories(c.Query("categories"))
+		errs.add("categories", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		grid, err, _ := memoize.Call(cache, lastChange(c.Request.Context(), db), func() ([]densityCell, error) {
+			return densityGrid(db)
+		})
+		if err != nil {
+			log.Printf("error computing density grid: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, EstimateResponse{
+			BBox:        bbox,
+			Estimate:    int(math.Round(estimateCount(grid, bbox, categories))),
+			CellSize:    densityCellSize,
+			Attribution: ATTRIBUTION,
+		})
+	}
+}
+
+// estimateCount sums the cells overlapping bbox, weighted by the fraction of
+// each cell's area inside it.
+func estimateCount(grid []densityCell, bbox []float64, categories map[string]struct{}) float64 {
+	overlap := func(lo, hi, from, to float64) float64 {
+		return max(0, min(hi, to)-max(lo, from)) / densityCellSize
+	}
+
+	estimate := 0.0
+	for _, cell := range grid {
+		south := float64(cell.row)*densityCellSize - 90
+		west := float64(cell.col)*densityCellSize - 180
+		fraction := overlap(south, south+densityCellSize, bbox[BOTTOM], bbox[TOP]) *
+			overlap(west, west+densityCellSize, bbox[LEFT], bbox[RIGHT])
+		if fraction == 0 {
+			continue
+		}
+
+		count := cell.total
+		if len(categories) > 0 {
+			count = 0
+			for category := range categories {
+				count += cell.categories[category]
+			}
+			count = min(count, cell.total)
+		}
+		estimate += fraction * float64(count)
+	}
+	return estimate
+}
+
+// densityGrid counts the POIs in each densityCellSize cell, leaving out the
+// empty ones.
+func densityGrid(db *sql.DB) ([]densityCell, error) {
+	cellRow := "CAST((" + column("lat") + " + 90) / ? AS INTEGER)"
+	cellCol := "CAST((" + column("long") + " + 180) / ? AS INTEGER)"
+	rows, err := db.Query(
+		"SELECT "+cellRow+", "+cellCol+", "+columnList("main_category", "alternate_category")+", COUNT(*) FROM "+table()+
+			" WHERE "+hasCoordinates()+" GROUP BY 1, 2, 3, 4",
+		densityCellSize, densityCellSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	cells := make(map[[2]int]*densityCell)
+	var row, col, count int
+	var mainCategory, alternateCategory sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&row, &col, &mainCategory, &alternateCategory, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		cell, ok := cells[[2]int{row, col}]
+		if !ok {
+			cell = &densityCell{row: row, col: col, categories: make(map[string]int)}
+			cells[[2]int{row, col}] = cell
+		}
+		cell.total += count
+		for _, category := range splitCategories(mainCategory, alternateCategory) {
+			cell.categories[strings.ToLower(strings.TrimSpace(category))] += count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	grid := make([]densityCell, 0, len(cells))
+	for _, cell := range cells {
+		grid = append(grid, *cell)
+	}
+	log.Printf("Computed POI density grid of %d cells", len(grid))
+	return grid, nil
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEstimateWithoutGeoPackageContents(t *testing.T) {
+	db := newTestDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/estimate", Estimate(db, 0))
+
+	var before EstimateResponse
+	getJSON(t, r, "/estimate?bbox=-2,54,-1,55", &before)
+	if before.Estimate != 7 {
+		t.Errorf("estimate = %d, want the 7 POIs with coordinates", before.Estimate)
+	}
+
+	if _, err := db.Exec(`DROP TABLE gpkg_contents`); err != nil {
+		t.Fatal(err)
+	}
+	var after EstimateResponse
+	getJSON(t, r, "/estimate?bbox=-2,54,-1,55", &after)
+	if after.Estimate != before.Estimate {
+		t.Errorf("estimate without gpkg_contents = %d, want %d", after.Estimate, before.Estimate)
+	}
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+type QueryPlanStep struct {
+	Id     int    `json:"id"`
+	Parent int    `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+type ExplainResponse struct {
+	SQL       string          `json:"sql"`
+	Args      []any           `json:"args"`
+	QueryPlan []QueryPlanStep `json:"query_plan"`
+	// RowCount is the number of rows matched by the SQL, before any
+	// filtering done in Go (such as by category).
+	RowCount int `json:"row_count"`
+	// SpatialIndex reports whether bbox predicates use the R-tree index.
+	SpatialIndex SpatialIndex `json:"spatial_index"`
+}
+
+// explainQuery describes how SQLite executes a query, without returning its
+// results.
+func explainQuery(db *sql.DB, query string, args []any) (*ExplainResponse, error) {
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error explaining query: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	plan := make([]QueryPlanStep, 0)
+	var notUsed int
+	for rows.Next() {
+		var step QueryPlanStep
+		if err := rows.Scan(&step.Id, &step.Parent, &notUsed, &step.Detail); err != nil {
+			return nil, fmt.Errorf("error scanning query plan: %w", err)
+		}
+		plan = append(plan, step)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&count); err != nil {
+		return nil, fmt.Errorf("error counting rows: %w", err)
+	}
+
+	return &ExplainResponse{
+		SQL:          query,
+		Args:         args,
+		QueryPlan:    plan,
+		RowCount:     count,
+		SpatialIndex: spatialIndex,
+	}, nil
+}
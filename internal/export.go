@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxExportRows bounds an export by the number of rows in its bbox.
+	maxExportRows = 1_000_000
+	// maxRunningExports bounds how many exports may be built at once.
+	maxRunningExports = 2
+)
+
+type exportFormat struct {
+	contentType string
+	extension   string
+}
+
+var exportFormats = map[string]exportFormat{
+	"ndjson": {contentType: "application/x-ndjson", extension: "ndjson"},
+	"csv":    {contentType: "text/csv", extension: "csv"},
+}
+
+// exportColumns are the CSV header; see csvRecord.
+var exportColumns = []string{
+	"fid", "id", "primary_name", "categories", "address", "locality", "postcode", "region",
+	"country", "source", "source_record_id", "lat", "long", "h3_15", "lsoa21cd",
+}
+
+type exportJob struct {
+	mu       sync.Mutex
+	format   exportFormat
+	path     string
+	total    int
+	scanned  int
+	rows     int
+	err      error
+	done     bool
+	finished time.Time
+}
+
+type ExportStatus struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	// Total is the number of POIs in the bbox, of which Scanned have been
+	// read so far and Rows written, after the category filter.
+	Total    int     `json:"total"`
+	Scanned  int     `json:"scanned"`
+	Rows     int     `json:"rows"`
+	Progress float64 `json:"progress"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func (j *exportJob) status(id string) ExportStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := ExportStatus{Id: id, Status: "running", Total: j.total, Scanned: j.scanned, Rows: j.rows, Progress: 1}
+	if j.total > 0 {
+		status.Progress = float64(j.scanned) / float64(j.total)
+	}
+	switch {
+	case j.err != nil:
+		status.Status = "failed"
+		status.Error = "An internal server error occurred"
+	case j.done:
+		status.Status = "done"
+	}
+	return status
+}
+
+// Exports builds large downloads in the background: each export is written
+// to a temporary file, which is kept for a TTL once built, so that neither a
+// request nor the results need be held open while it runs.
+type Exports struct {
+	db   *sql.DB
+	ttl  time.Duration
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+// NewExports starts the clean-up of exports older than ttl.
+func NewExports(db *sql.DB, ttl time.Duration) *Exports {
+	e := &Exports{db: db, ttl: ttl, jobs: make(map[string]*exportJob)}
+	go func() {
+		for range time.Tick(min(ttl, time.Minute)) {
+			e.expire()
+		}
+	}()
+	return e
+}
+
+// Start begins an export of the POIs in a bbox, optionally filtered by
+// categories, as NDJSON (the default) or with ?format=csv. It responds with
+// a 202 and the job's status, whose id is polled with Get.
+func (e *Exports) Start(c *gin.Context) {
+	var errs paramErrors
+	bbox, err := parseBBox(c.Query("bbox"))
+	errs.add("bbox", err)
+	categories, err := parseCategories(c.Query("categories"))
+	errs.add("categories", err)
+	format, ok := exportFormats[c.DefaultQuery("format", "ndjson")]
+	if !ok {
+		errs.add("format", fmt.Errorf("format must be ndjson or csv"))
+	}
+	if len(errs) > 0 {
+		errs.respond(c)
+		return
+	}
+
+	where, args := bboxClause(), bboxArgs(bbox)
+	var total int
+	if err := e.db.QueryRowContext(c.Request.Context(), "SELECT COUNT(*) FROM "+table()+" WHERE "+where, args...).Scan(&total); err != nil {
+		log.Printf("error counting export rows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+	if total > maxExportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the bbox has %d POIs, exceeding the export limit of %d", total, maxExportRows)})
+		return
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		log.Printf("error creating export id: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+
+	file, err := os.CreateTemp("", "poi-export-*."+format.extension)
+	if err != nil {
+		log.Printf("error creating export file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+	job := &exportJob{format: format, path: file.Name(), total: total}
+
+	e.mu.Lock()
+	running := 0
+	for _, j := range e.jobs {
+		j.mu.Lock()
+		if !j.done {
+			running++
+		}
+		j.mu.Unlock()
+	}
+	if running >= maxRunningExports {
+		e.mu.Unlock()
+		removeExportFile(file)
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many exports are already running; try again shortly"})
+		return
+	}
+	e.jobs[id] = job
+	e.mu.Unlock()
+
+	go func() {
+		err := writeExport(file, job, e.db, where, args, categories)
+		if err != nil {
+			log.Printf("error building export %s: %v", id, err)
+		}
+
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.err = err
+		job.done = true
+		job.finished = time.Now()
+	}()
+
+	c.JSON(http.StatusAccepted, job.status(id))
+}
+
+// Get reports an export's progress while it is being built, and once done
+// serves the file.
+func (e *Exports) Get(c *gin.Context) {
+	// Progress changes, and an id stops working once expired.
+	c.Header("Cache-Control", "no-store")
+
+	id := c.Param("id")
+	e.mu.Lock()
+	job, ok := e.jobs[id]
+	e.mu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+		return
+	}
+
+	status := job.status(id)
+	switch status.Status {
+	case "running":
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusAccepted, status)
+	case "failed":
+		c.JSON(http.StatusInternalServerError, status)
+	default:
+		c.Header("Content-Type", job.format.contentType)
+		c.FileAttachment(job.path, "poi-export."+job.format.extension)
+	}
+}
+
+// expire forgets exports finished over ttl ago, removing their files.
+func (e *Exports) expire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for id, job := range e.jobs {
+		job.mu.Lock()
+		expired := job.done && time.Since(job.finished) > e.ttl
+		job.mu.Unlock()
+		if !expired {
+			continue
+		}
+
+		if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("error removing export file: %v", err)
+		}
+		delete(e.jobs, id)
+	}
+}
+
+func writeExport(file *os.File, job *exportJob, db *sql.DB, where string, args []any, categories map[string]struct{}) (err error) {
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("error closing export file: %w", cerr)
+		}
+	}()
+
+	rows, err := db.QueryContext(context.Background(),
+		"SELECT "+columnList(selectedColumns()...)+" FROM "+table()+" WHERE "+where+" ORDER BY "+column("fid"),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	w := bufio.NewWriter(file)
+	var enc *json.Encoder
+	var cw *csv.Writer
+	if job.format.extension == "csv" {
+		cw = csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return fmt.Errorf("error writing export: %w", err)
+		}
+	} else {
+		enc = json.NewEncoder(w)
+	}
+
+	for rows.Next() {
+		poi, err := scanPOI(rows, scanOptions{})
+		if err != nil {
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+
+		keep := len(categories) == 0 || hasCategoryMatch(poi.Categories, categories)
+		if keep {
+			if cw != nil {
+				err = cw.Write(csvRecord(poi))
+			} else {
+				err = enc.Encode(poi)
+			}
+			if err != nil {
+				return fmt.Errorf("error writing export: %w", err)
+			}
+		}
+
+		job.mu.Lock()
+		job.scanned++
+		if keep {
+			job.rows++
+		}
+		job.mu.Unlock()
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("error writing export: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing export: %w", err)
+	}
+	return nil
+}
+
+// csvRecord flattens a POI into a row of exportColumns. Categories are
+// joined with the alternate_category delimiter, main category first.
+func csvRecord(poi POI) []string {
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	coord := func(c Coordinate) string {
+		return strconv.FormatFloat(float64(c), 'f', -1, 64)
+	}
+
+	return []string{
+		strconv.Itoa(poi.Fid), poi.Id, str(poi.PrimaryName), strings.Join(poi.Categories, categoryDelimiter),
+		str(poi.Address), str(poi.Locality), str(poi.Postcode), str(poi.Region), str(poi.Country),
+		poi.Source, poi.SourceRecordId, coord(poi.Lat), coord(poi.Long), poi.H3_15, poi.LSOA21CD,
+	}
+}
+
+func removeExportFile(file *os.File) {
+	if err := file.Close(); err != nil {
+		log.Printf("error closing export file: %v", err)
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		log.Printf("error removing export file: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+	"github.com/twpayne/go-geom/xy"
+)
+
+// Extent returns a GeoJSON Feature enclosing every POI of a category: by
+// default its bounding box, or with ?shape=hull its convex hull. A bbox only
+// needs the running min and max, whereas a hull holds every point of the
+// category in memory and sorts them, O(n log n); both scan the whole table.
+func Extent(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		category := strings.ToLower(strings.TrimSpace(c.Query("category")))
+		if category == "" {
+			errs.add("category", fmt.Errorf("category is required"))
+		}
+		shape := c.DefaultQuery("shape", "bbox")
+		if shape != "bbox" && shape != "hull" {
+			errs.add("shape", fmt.Errorf("shape must be bbox or hull"))
+		}
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		// LIKE narrows the alternate categories down to candidates, which
+		// are then matched exactly once split.
+		rows, err := db.QueryContext(c.Request.Context(),
+			"SELECT "+columnList("lat", "long", "main_category", "alternate_category")+" FROM "+table()+
+				" WHERE ("+column("main_category")+" = ? OR "+column("alternate_category")+` LIKE ? ESCAPE '\') AND `+hasCoordinates(),
+			category, "%"+likeEscaper.Replace(category)+"%",
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		wanted := map[string]struct{}{category: {}}
+		var bounds *ResultBounds
+		var coords []float64
+		count := 0
+		for rows.Next() {
+			var poi POI
+			var mainCategory, alternateCategory sql.NullString
+			if err := rows.Scan(&poi.Lat, &poi.Long, &mainCategory, &alternateCategory); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			if !hasCategoryMatch(splitCategories(mainCategory, alternateCategory), wanted) {
+				continue
+			}
+
+			count++
+			bounds = bounds.extend(poi)
+			if shape == "hull" {
+				coords = append(coords, float64(poi.Long), float64(poi.Lat))
+			}
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no POIs found for category"})
+			return
+		}
+
+		var g geom.T
+		if shape == "hull" {
+			g = xy.ConvexHullFlat(geom.XY, coords)
+		} else {
+			g = geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{{
+				{float64(bounds.MinLong), float64(bounds.MinLat)},
+				{float64(bounds.MaxLong), float64(bounds.MinLat)},
+				{float64(bounds.MaxLong), float64(bounds.MaxLat)},
+				{float64(bounds.MinLong), float64(bounds.MaxLat)},
+				{float64(bounds.MinLong), float64(bounds.MinLat)},
+			}})
+		}
+
+		feature, err := (&geojson.Feature{
+			Geometry: g,
+			Properties: map[string]any{
+				"category": category,
+				"count":    count,
+				"shape":    shape,
+			},
+		}).MarshalJSON()
+		if err != nil {
+			log.Printf("error encoding extent: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.Data(http.StatusOK, "application/geo+json", feature)
+	}
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// filteredTotal counts the search rows fetched from the database but dropped
+// in-process, by the filter that dropped them.
+var filteredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "poi_filtered_total",
+	Help: "Search rows fetched from the database but dropped by a filter applied after the query.",
+}, []string{"filter"})
+
+// filterStats tallies a single search's rows, so that an empty response can
+// be told apart from one where every row in the bbox was filtered out.
+type filterStats struct {
+	fetched int
+	dropped map[string]int
+}
+
+func newFilterStats() *filterStats {
+	return &filterStats{dropped: make(map[string]int)}
+}
+
+func (s *filterStats) drop(filter string, n int) {
+	if n > 0 {
+		s.dropped[filter] += n
+	}
+}
+
+// record adds the dropped rows to poi_filtered_total and, if verbose, logs
+// the selectivity of the search's filters. Counters are only touched once
+// per request, keeping them off the per-row path.
+func (s *filterStats) record(verbose bool) {
+	kept := s.fetched
+	for filter, n := range s.dropped {
+		filteredTotal.WithLabelValues(filter).Add(float64(n))
+		kept -= n
+	}
+
+	if verbose && len(s.dropped) > 0 {
+		log.Printf("DEBUG: search fetched %d rows, kept %d, dropped %v", s.fetched, kept, s.dropped)
+	}
+}
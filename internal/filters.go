@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// likeEscaper escapes the LIKE wildcards (and the escape character itself)
+// so user input is matched literally by a predicate using ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePrefix returns a LIKE predicate matching rows whose column starts with
+// prefix, along with its bound argument. The column must come from code, never
+// from user input; only prefix is treated as untrusted.
+func likePrefix(column string, prefix string) (string, any) {
+	return column + ` LIKE ? ESCAPE '\'`, likeEscaper.Replace(prefix) + "%"
+}
+
+// likeContains returns a LIKE predicate matching rows whose column contains
+// substr anywhere, which, unlike likePrefix, no index can serve.
+func likeContains(column string, substr string) (string, any) {
+	return column + ` LIKE ? ESCAPE '\'`, "%" + likeEscaper.Replace(substr) + "%"
+}
+
+// sortColumns is the allow-list of values accepted by the sort parameter,
+// mapped to the logical column each orders by.
+var sortColumns = map[string]string{
+	"name":     "primary_name",
+	"locality": "locality",
+	"postcode": "postcode",
+	"source":   "source",
+}
+
+// orderClause returns the ORDER BY clause for a sort parameter: an optional
+// allow-listed field, prefixed with "-" for descending order. fid is always
+// appended as the final tiebreaker so results are deterministic.
+func orderClause(sort string) (string, error) {
+	if sort == "" {
+		return " ORDER BY " + column("fid"), nil
+	}
+
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = sort[1:]
+	}
+
+	name, ok := sortColumns[sort]
+	if !ok {
+		return "", fmt.Errorf("invalid sort value '%s'", sort)
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, %s", column(name), direction, column("fid")), nil
+}
+
+// parseLimit parses an optional positive integer limit; zero means unlimited.
+func parseLimit(name string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid %s value '%s': must be a positive integer", name, value)
+	}
+
+	return limit, nil
+}
+
+// mainCategory is the category a POI is grouped under, or "" if it has none.
+func mainCategory(poi POI) string {
+	if len(poi.Categories) == 0 {
+		return ""
+	}
+	return poi.Categories[0]
+}
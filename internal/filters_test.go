@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLikeEscaping(t *testing.T) {
+	db, err := testutil.NewDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	names := []string{"100% Fresh", "1000 Fresh", "Bar_Code", "BarXCode", `Back\Slash`, "BackSlash"}
+	fixtures := make([]testutil.Fixture, len(names))
+	for i, name := range names {
+		fixtures[i] = testutil.Fixture{Id: name, PrimaryName: &name, Source: "test", SourceRecordId: name, Lat: 55, Long: -1.6}
+	}
+	if err := testutil.Seed(db, fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		predicate func(string, string) (string, any)
+		value     string
+		want      []string
+	}{
+		{"prefix percent", likePrefix, "100%", []string{"100% Fresh"}},
+		{"prefix underscore", likePrefix, "Bar_", []string{"Bar_Code"}},
+		{"prefix backslash", likePrefix, `Back\`, []string{`Back\Slash`}},
+		{"prefix plain", likePrefix, "Bar", []string{"Bar_Code", "BarXCode"}},
+		{"contains percent", likeContains, "%", []string{"100% Fresh"}},
+		{"contains underscore", likeContains, "_", []string{"Bar_Code"}},
+		{"contains backslash", likeContains, `\`, []string{`Back\Slash`}},
+		{"contains escaped sequence", likeContains, `k\S`, []string{`Back\Slash`}},
+		{"contains plain", likeContains, "Fresh", []string{"100% Fresh", "1000 Fresh"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clause, arg := tc.predicate("primary_name", tc.value)
+			rows, err := db.Query("SELECT primary_name FROM poi_uk WHERE "+clause+" ORDER BY primary_name", arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = rows.Close() }()
+
+			var got []string
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, name)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(tc.want)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("%s %q matched %q, want %q", tc.name, tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchTreatsInjectionLiterally(t *testing.T) {
+	db := newTestDB(t)
+
+	const injection = "x'; DROP TABLE poi_uk; --"
+	fixtures := []testutil.Fixture{
+		{Id: "injection", PrimaryName: ptr(injection), Postcode: ptr("NE1'; DROP TABLE poi_uk; --"), Source: "test", SourceRecordId: "1", Lat: 55, Long: -1.6},
+		{Id: "plain", PrimaryName: ptr("x"), Postcode: ptr("NE1 1AA"), Source: "test", SourceRecordId: "2", Lat: 55, Long: -1.6},
+	}
+	if err := testutil.Seed(db, fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", Search(db, SearchConfig{}))
+
+	ids := func(pois []POI) []string {
+		var ids []string
+		for _, poi := range pois {
+			ids = append(ids, poi.Id)
+		}
+		return ids
+	}
+
+	for _, tc := range []struct {
+		param, value string
+		want         []string
+	}{
+		{"q", injection, []string{"injection"}},
+		{"q", "x' OR '1'='1", nil},
+		{"postcode", "NE1'; DROP TABLE poi_uk; --", []string{"injection"}},
+		{"postcode", "NE1' OR '1'='1", nil},
+	} {
+		query := url.Values{"bbox": {"-1.61,54.99,-1.59,55.01"}, tc.param: {tc.value}}
+		if got := ids(search(t, r, query.Encode()).Results); !slices.Equal(got, tc.want) {
+			t.Errorf("%s=%s matched %v, want %v", tc.param, tc.value, got, tc.want)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM poi_uk`).Scan(&count); err != nil {
+		t.Fatalf("poi_uk is gone: %v", err)
+	}
+	if want := len(testutil.Fixtures) + len(fixtures); count != want {
+		t.Errorf("poi_uk has %d rows, want %d", count, want)
+	}
+}
+
+func TestSearchRejectsFieldsOutsideAllowList(t *testing.T) {
+	r := newTestSearch(t, SearchConfig{})
+
+	// Ordering and grouping fields are only ever taken from allow-lists, so
+	// anything else is turned away rather than reaching the SQL.
+	for _, query := range []url.Values{
+		{"sort": {"name; DROP TABLE poi_uk; --"}},
+		{"sort": {"-name DESC, (SELECT 1)"}},
+		// A real column, but not one of the sortable fields.
+		{"sort": {"primary_name"}},
+		{"sort": {"1"}},
+		{"group_by": {"id; DROP TABLE poi_uk"}},
+		{"group_by": {"primary_name"}},
+	} {
+		query.Set("bbox", fixturesBBox)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query.Encode(), nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query.Encode(), http.StatusBadRequest, w.Code)
+		}
+	}
+
+	// The allow-listed fields themselves are accepted.
+	search(t, r, "bbox="+fixturesBBox+"&sort=-name&group_by=id")
+}
@@ -0,0 +1,93 @@
+// Package geo provides the small set of spherical-earth helpers shared by the
+// distance, radius and corridor searches.
+package geo
+
+import (
+	"math"
+
+	"github.com/twpayne/go-geom"
+)
+
+// EarthRadiusMetres is the mean radius of the earth.
+const EarthRadiusMetres = 6371008.8
+
+// MetresPerDegree is the length of one degree of latitude (or of longitude
+// at the equator).
+const MetresPerDegree = EarthRadiusMetres * math.Pi / 180
+
+// Haversine returns the great-circle distance in metres between two points.
+func Haversine(lat1, long1, lat2, long2 float64) float64 {
+	φ1 := lat1 * math.Pi / 180
+	φ2 := lat2 * math.Pi / 180
+	Δφ := (lat2 - lat1) * math.Pi / 180
+	Δλ := (long2 - long1) * math.Pi / 180
+
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(Δλ/2)*math.Sin(Δλ/2)
+	return 2 * EarthRadiusMetres * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// BBoxFromRadius returns the [LEFT, BOTTOM, RIGHT, TOP] box enclosing every
+// point within radius metres of (lat, long). Latitudes are clamped to the
+// poles; near them the box widens to span all longitudes.
+func BBoxFromRadius(lat, long, radius float64) []float64 {
+	dLat := radius / MetresPerDegree
+	bottom := math.Max(lat-dLat, -90)
+	top := math.Min(lat+dLat, 90)
+
+	// Longitude degrees shrink with latitude, so use the latitude nearest a
+	// pole to be sure the whole circle is enclosed.
+	cosLat := math.Cos(math.Max(math.Abs(bottom), math.Abs(top)) * math.Pi / 180)
+	if cosLat <= 0 || radius/(MetresPerDegree*cosLat) >= 180 {
+		return []float64{-180, bottom, 180, top}
+	}
+
+	dLong := radius / (MetresPerDegree * cosLat)
+	return []float64{long - dLong, bottom, long + dLong, top}
+}
+
+// DistanceToSegment returns the approximate distance in metres from a point
+// to the segment a-b, using an equirectangular projection centred on the
+// point. This is accurate for the short distances corridor searches use.
+func DistanceToSegment(lat, long, latA, longA, latB, longB float64) float64 {
+	kx := math.Cos(lat*math.Pi/180) * MetresPerDegree
+
+	ax, ay := (longA-long)*kx, (latA-lat)*MetresPerDegree
+	bx, by := (longB-long)*kx, (latB-lat)*MetresPerDegree
+
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+	}
+
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// PointInPolygon reports whether (lat, long) lies inside polygon, which is in
+// long/lat order. Points inside a hole are outside the polygon.
+func PointInPolygon(lat, long float64, polygon *geom.Polygon) bool {
+	if polygon.NumLinearRings() == 0 || !inRing(lat, long, polygon.LinearRing(0)) {
+		return false
+	}
+
+	for i := 1; i < polygon.NumLinearRings(); i++ {
+		if inRing(lat, long, polygon.LinearRing(i)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inRing is the even-odd ray casting test.
+func inRing(lat, long float64, ring *geom.LinearRing) bool {
+	inside := false
+	n := ring.NumCoords()
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := ring.Coord(i), ring.Coord(j)
+		if (a.Y() > lat) != (b.Y() > lat) && long < (b.X()-a.X())*(lat-a.Y())/(b.Y()-a.Y())+a.X() {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,127 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/twpayne/go-geom"
+)
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		name                     string
+		lat1, long1, lat2, long2 float64
+		want                     float64
+	}{
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 344e3},
+		{"London to Edinburgh", 51.5074, -0.1278, 55.9533, -3.1883, 534e3},
+		{"Manchester to Liverpool", 53.4808, -2.2426, 53.4084, -2.9916, 50.3e3},
+		{"New York to Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 3936e3},
+		{"same point", 54.9783, -1.6178, 54.9783, -1.6178, 0},
+		{"one degree of latitude", 0, 0, 1, 0, MetresPerDegree},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Haversine(tc.lat1, tc.long1, tc.lat2, tc.long2)
+			if math.Abs(got-tc.want) > 0.005*tc.want {
+				t.Errorf("Haversine = %.0fm, want %.0fm", got, tc.want)
+			}
+			if back := Haversine(tc.lat2, tc.long2, tc.lat1, tc.long1); math.Abs(back-got) > 1e-6 {
+				t.Errorf("Haversine is not symmetric: %.3fm one way, %.3fm the other", got, back)
+			}
+		})
+	}
+}
+
+func TestBBoxFromRadius(t *testing.T) {
+	t.Run("encloses the circle", func(t *testing.T) {
+		lat, long, radius := 54.9783, -1.6178, 5000.0
+		bbox := BBoxFromRadius(lat, long, radius)
+		left, bottom, right, top := bbox[0], bbox[1], bbox[2], bbox[3]
+
+		// The points due north, south, east and west of the centre, at
+		// the radius, must all lie inside the box.
+		if d := Haversine(lat, long, top, long); d < radius {
+			t.Errorf("top edge is %.0fm from the centre, inside the radius", d)
+		}
+		if d := Haversine(lat, long, bottom, long); d < radius {
+			t.Errorf("bottom edge is %.0fm from the centre, inside the radius", d)
+		}
+		if d := Haversine(lat, long, lat, left); d < radius {
+			t.Errorf("left edge is %.0fm from the centre, inside the radius", d)
+		}
+		if d := Haversine(lat, long, lat, right); d < radius {
+			t.Errorf("right edge is %.0fm from the centre, inside the radius", d)
+		}
+		if right-left > 2*(top-bottom) {
+			t.Errorf("bbox %v is much wider than the circle needs", bbox)
+		}
+	})
+
+	t.Run("clamps at the pole", func(t *testing.T) {
+		bbox := BBoxFromRadius(89.99, 10, 10000)
+		want := []float64{-180, 89.99 - 10000/MetresPerDegree, 180, 90}
+		for i := range want {
+			if math.Abs(bbox[i]-want[i]) > 1e-9 {
+				t.Fatalf("BBoxFromRadius near the pole = %v, want %v", bbox, want)
+			}
+		}
+	})
+}
+
+func TestDistanceToSegment(t *testing.T) {
+	tests := []struct {
+		name                                string
+		lat, long, latA, longA, latB, longB float64
+		want                                float64
+	}{
+		{"beside the middle", 0.001, 0.5, 0, 0, 0, 1, 0.001 * MetresPerDegree},
+		{"beyond end a", 0, -0.001, 0, 0, 0, 1, 0.001 * MetresPerDegree},
+		{"beyond end b", 0.001, 1, 0, 0, 0, 1, 0.001 * MetresPerDegree},
+		{"on the segment", 0, 0.25, 0, 0, 0, 1, 0},
+		{"degenerate segment", 55.001, -1.6, 55, -1.6, 55, -1.6, 0.001 * MetresPerDegree},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DistanceToSegment(tc.lat, tc.long, tc.latA, tc.longA, tc.latB, tc.longB)
+			if math.Abs(got-tc.want) > 0.5 {
+				t.Errorf("DistanceToSegment = %.1fm, want %.1fm", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	// A 4x4 square at the origin with a 2x2 hole in the middle, in long/lat
+	// order.
+	polygon := geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+		{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}},
+	})
+
+	tests := []struct {
+		name      string
+		lat, long float64
+		want      bool
+	}{
+		{"inside", 0.5, 0.5, true},
+		{"inside the hole", 2, 2, false},
+		{"outside", 5, 2, false},
+		{"between the hole and the edge", 2, 3.5, true},
+		{"east of the polygon", 0.5, 4.5, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PointInPolygon(tc.lat, tc.long, polygon); got != tc.want {
+				t.Errorf("PointInPolygon(%g, %g) = %t, want %t", tc.lat, tc.long, got, tc.want)
+			}
+		})
+	}
+
+	if PointInPolygon(0.5, 0.5, geom.NewPolygon(geom.XY)) {
+		t.Errorf("PointInPolygon with an empty polygon = true, want false")
+	}
+}
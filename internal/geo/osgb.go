@@ -0,0 +1,117 @@
+package geo
+
+import "math"
+
+// Airy 1830 ellipsoid and National Grid projection constants, from the
+// Ordnance Survey's "A Guide to Coordinate Systems in Great Britain".
+const (
+	airyA  = 6377563.396
+	airyB  = 6356256.909
+	gridF0 = 0.9996012717
+	gridN0 = -100000.0
+	gridE0 = 400000.0
+	gridφ0 = 49 * math.Pi / 180
+	gridλ0 = -2 * math.Pi / 180
+
+	wgs84A = 6378137.0
+	wgs84B = 6356752.3142
+)
+
+// OSGB36 to WGS84 Helmert transform parameters: translations in metres,
+// scale in ppm and rotations in arc-seconds. The OS guide lists them for
+// WGS84 to OSGB36; going this way, every sign is reversed.
+const (
+	helmertTx = 446.448
+	helmertTy = -125.157
+	helmertTz = 542.060
+	helmertS  = -20.4894
+	helmertRx = 0.1502
+	helmertRy = 0.2470
+	helmertRz = 0.8421
+)
+
+// OSGridToWGS84 converts a British National Grid (EPSG:27700) easting and
+// northing to WGS84 (EPSG:4326) latitude and longitude. The Helmert transform
+// is accurate to a few metres, which is ample for placing POIs.
+func OSGridToWGS84(easting, northing float64) (float64, float64) {
+	φ, λ := inverseTransverseMercator(easting, northing)
+	x, y, z := toCartesian(φ, λ, airyA, airyB)
+	x, y, z = helmert(x, y, z)
+	φ, λ = fromCartesian(x, y, z, wgs84A, wgs84B)
+	return φ * 180 / math.Pi, λ * 180 / math.Pi
+}
+
+func inverseTransverseMercator(E, N float64) (float64, float64) {
+	a, b := airyA, airyB
+	e2 := 1 - (b*b)/(a*a)
+	n := (a - b) / (a + b)
+
+	φ := gridφ0
+	M := 0.0
+	for {
+		φ = (N-gridN0-M)/(a*gridF0) + φ
+		M = meridionalArc(φ, n, b)
+		if math.Abs(N-gridN0-M) < 0.00001 {
+			break
+		}
+	}
+
+	sinφ, cosφ, tanφ := math.Sin(φ), math.Cos(φ), math.Tan(φ)
+	ν := a * gridF0 / math.Sqrt(1-e2*sinφ*sinφ)
+	ρ := a * gridF0 * (1 - e2) / math.Pow(1-e2*sinφ*sinφ, 1.5)
+	η2 := ν/ρ - 1
+
+	tan2, tan4, tan6 := tanφ*tanφ, math.Pow(tanφ, 4), math.Pow(tanφ, 6)
+	secφ := 1 / cosφ
+
+	VII := tanφ / (2 * ρ * ν)
+	VIII := tanφ / (24 * ρ * math.Pow(ν, 3)) * (5 + 3*tan2 + η2 - 9*tan2*η2)
+	IX := tanφ / (720 * ρ * math.Pow(ν, 5)) * (61 + 90*tan2 + 45*tan4)
+	X := secφ / ν
+	XI := secφ / (6 * math.Pow(ν, 3)) * (ν/ρ + 2*tan2)
+	XII := secφ / (120 * math.Pow(ν, 5)) * (5 + 28*tan2 + 24*tan4)
+	XIIA := secφ / (5040 * math.Pow(ν, 7)) * (61 + 662*tan2 + 1320*tan4 + 720*tan6)
+
+	dE := E - gridE0
+	lat := φ - VII*math.Pow(dE, 2) + VIII*math.Pow(dE, 4) - IX*math.Pow(dE, 6)
+	long := gridλ0 + X*dE - XI*math.Pow(dE, 3) + XII*math.Pow(dE, 5) - XIIA*math.Pow(dE, 7)
+	return lat, long
+}
+
+func meridionalArc(φ, n, b float64) float64 {
+	n2, n3 := n*n, n*n*n
+	dφ, sφ := φ-gridφ0, φ+gridφ0
+	return b * gridF0 * ((1+n+5.0/4*n2+5.0/4*n3)*dφ -
+		(3*n+3*n2+21.0/8*n3)*math.Sin(dφ)*math.Cos(sφ) +
+		(15.0/8*n2+15.0/8*n3)*math.Sin(2*dφ)*math.Cos(2*sφ) -
+		35.0/24*n3*math.Sin(3*dφ)*math.Cos(3*sφ))
+}
+
+func toCartesian(φ, λ, a, b float64) (float64, float64, float64) {
+	e2 := 1 - (b*b)/(a*a)
+	sinφ := math.Sin(φ)
+	ν := a / math.Sqrt(1-e2*sinφ*sinφ)
+	return ν * math.Cos(φ) * math.Cos(λ), ν * math.Cos(φ) * math.Sin(λ), (1 - e2) * ν * sinφ
+}
+
+func helmert(x, y, z float64) (float64, float64, float64) {
+	s := 1 + helmertS/1e6
+	rx := helmertRx / 3600 * math.Pi / 180
+	ry := helmertRy / 3600 * math.Pi / 180
+	rz := helmertRz / 3600 * math.Pi / 180
+	return helmertTx + s*x - rz*y + ry*z,
+		helmertTy + rz*x + s*y - rx*z,
+		helmertTz - ry*x + rx*y + s*z
+}
+
+func fromCartesian(x, y, z, a, b float64) (float64, float64) {
+	e2 := 1 - (b*b)/(a*a)
+	p := math.Hypot(x, y)
+	φ := math.Atan2(z, p*(1-e2))
+	for range 10 {
+		sinφ := math.Sin(φ)
+		ν := a / math.Sqrt(1-e2*sinφ*sinφ)
+		φ = math.Atan2(z+e2*ν*sinφ, p)
+	}
+	return φ, math.Atan2(y, x)
+}
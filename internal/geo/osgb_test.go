@@ -0,0 +1,14 @@
+package geo
+
+import "testing"
+
+func TestOSGridToWGS84(t *testing.T) {
+	// The worked example from the OS guide, Caister Water Tower: OSGB36
+	// 52°39'27.2531"N 1°43'4.5177"E, which is about 52.65798°N 1.71605°E
+	// in WGS84.
+	lat, long := OSGridToWGS84(651409.903, 313177.270)
+
+	if d := Haversine(lat, long, 52.65798, 1.71605); d > 5 {
+		t.Errorf("OSGridToWGS84(651409.903, 313177.270) = %f, %f: %.1fm from the expected position", lat, long, d)
+	}
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"geods-poi-api/internal/geo"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+	"github.com/twpayne/go-geom/encoding/wkt"
+)
+
+const (
+	sridWGS84 = 4326
+	sridOSGB  = 27700
+	// sridUndefinedGeographic is the GeoPackage placeholder for geographic
+	// coordinates in an unspecified CRS, which is treated as WGS84.
+	sridUndefinedGeographic = 0
+)
+
+// envelopeSizes maps the envelope contents indicator in the GeoPackage binary
+// header flags onto the size in bytes of the envelope that follows the SRID.
+var envelopeSizes = map[byte]int{0: 0, 1: 32, 2: 48, 3: 48, 4: 64}
+
+// parseGeoPackageHeader splits a GeoPackage geometry blob into the SRID from
+// its header and the standard WKB payload following the header and envelope.
+func parseGeoPackageHeader(geomBytes []byte) (int32, []byte, error) {
+	if len(geomBytes) < 8 {
+		return 0, nil, fmt.Errorf("input byte slice is too short to contain a GeoPackage header and WKB data")
+	}
+	if geomBytes[0] != 'G' || geomBytes[1] != 'P' {
+		return 0, nil, fmt.Errorf("geometry is missing the GeoPackage magic number")
+	}
+
+	flags := geomBytes[3]
+	var order binary.ByteOrder = binary.BigEndian
+	if flags&0x01 != 0 {
+		order = binary.LittleEndian
+	}
+	srid := int32(order.Uint32(geomBytes[4:8]))
+
+	envelopeSize, ok := envelopeSizes[(flags>>1)&0x07]
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid GeoPackage envelope indicator in flags 0x%02x", flags)
+	}
+	if len(geomBytes) < 8+envelopeSize {
+		return 0, nil, fmt.Errorf("GeoPackage header is truncated")
+	}
+
+	return srid, geomBytes[8+envelopeSize:], nil
+}
+
+// geomFormat selects how POI geometries are written in responses.
+type geomFormat int
+
+const (
+	// geomWKT writes geometries as WKT strings, e.g. "POINT (-1.61 54.97)".
+	geomWKT geomFormat = iota
+	// geomCoords writes geometries as GeoJSON-style [long, lat] arrays.
+	geomCoords
+	// geomWKBBase64 writes the stored WKB, without the GeoPackage header,
+	// base64-encoded, so coordinates and any Z or M values are exact.
+	geomWKBBase64
+)
+
+func parseGeomFormat(value string) (geomFormat, error) {
+	switch value {
+	case "", "wkt":
+		return geomWKT, nil
+	case "coords":
+		return geomCoords, nil
+	case "wkb_b64":
+		return geomWKBBase64, nil
+	default:
+		return geomWKT, fmt.Errorf("invalid geom_format value '%s': must be 'wkt', 'coords' or 'wkb_b64'", value)
+	}
+}
+
+// decodeGeometry decodes a GeoPackage point into the given format; see
+// decodePoint.
+func decodeGeometry(geomBytes []byte, format geomFormat) (any, int32, error) {
+	if format == geomWKBBase64 {
+		return storedWKB([][]byte{geomBytes})
+	}
+
+	point, srid, err := decodePoint(geomBytes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	g, err := formatGeometry(point, format)
+	return g, srid, err
+}
+
+// decodeMultiPoint decodes several GeoPackage points into a single MultiPoint
+// in the given format, or a Point if there is only one.
+func decodeMultiPoint(geoms [][]byte, format geomFormat) (any, int32, error) {
+	if len(geoms) == 1 {
+		return decodeGeometry(geoms[0], format)
+	}
+	if format == geomWKBBase64 {
+		return storedWKB(geoms)
+	}
+
+	multiPoint := geom.NewMultiPoint(geom.XY)
+	var srid int32
+	for _, geomBytes := range geoms {
+		point, pointSRID, err := decodePoint(geomBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := multiPoint.Push(point); err != nil {
+			return nil, 0, fmt.Errorf("error building MultiPoint: %w", err)
+		}
+		srid = pointSRID
+	}
+
+	g, err := formatGeometry(multiPoint, format)
+	return g, srid, err
+}
+
+// storedWKB returns the base64-encoded WKB of a GeoPackage point as stored,
+// neither reprojected nor re-encoded, along with its SRID. Several points are
+// combined into a MultiPoint, which must all share an SRID and layout.
+func storedWKB(geoms [][]byte) (any, int32, error) {
+	var multiPoint *geom.MultiPoint
+	var srid int32
+	for i, geomBytes := range geoms {
+		pointSRID, wkbData, err := parseGeoPackageHeader(geomBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		g, err := wkb.Unmarshal(wkbData)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error unmarshaling WKB: %w", err)
+		}
+		point, ok := g.(*geom.Point)
+		if !ok {
+			return nil, 0, fmt.Errorf("decoded geometry is not a Point, but a %T", g)
+		}
+
+		if len(geoms) == 1 {
+			return base64.StdEncoding.EncodeToString(wkbData), pointSRID, nil
+		}
+		if i == 0 {
+			multiPoint = geom.NewMultiPoint(point.Layout())
+			srid = pointSRID
+		} else if pointSRID != srid {
+			return nil, 0, fmt.Errorf("grouped geometries have differing SRIDs %d and %d", srid, pointSRID)
+		}
+		if err := multiPoint.Push(point); err != nil {
+			return nil, 0, fmt.Errorf("error building MultiPoint: %w", err)
+		}
+	}
+
+	wkbData, err := wkb.Marshal(multiPoint, binary.LittleEndian)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error marshaling to WKB: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(wkbData), srid, nil
+}
+
+// decodePoint decodes a GeoPackage point in WGS84, reprojecting from British
+// National Grid if need be, and returns the SRID it was stored in. Other SRIDs
+// are rejected rather than returned as mislabelled coordinates.
+func decodePoint(geomBytes []byte) (*geom.Point, int32, error) {
+	srid, wkbData, err := parseGeoPackageHeader(geomBytes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	g, err := wkb.Unmarshal(wkbData)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error unmarshaling WKB: %w", err)
+	}
+
+	point, ok := g.(*geom.Point)
+	if !ok {
+		return nil, 0, fmt.Errorf("decoded geometry is not a Point, but a %T", g)
+	}
+
+	switch srid {
+	case sridWGS84, sridUndefinedGeographic:
+	case sridOSGB:
+		lat, long := geo.OSGridToWGS84(point.X(), point.Y())
+		point = geom.NewPointFlat(geom.XY, []float64{long, lat})
+	default:
+		return nil, 0, fmt.Errorf("unsupported geometry SRID %d: only 4326 and 27700 are supported", srid)
+	}
+
+	return point, srid, nil
+}
+
+// formatGeometry writes a Point or MultiPoint as WKT, or as GeoJSON-style
+// coordinates: [long, lat] for a Point, [[long, lat], ...] for a MultiPoint.
+func formatGeometry(g geom.T, format geomFormat) (any, error) {
+	if format == geomCoords {
+		switch g := g.(type) {
+		case *geom.Point:
+			return []Coordinate{Coordinate(g.X()), Coordinate(g.Y())}, nil
+		case *geom.MultiPoint:
+			coords := make([][]Coordinate, 0, g.NumPoints())
+			for i := range g.NumPoints() {
+				point := g.Point(i)
+				coords = append(coords, []Coordinate{Coordinate(point.X()), Coordinate(point.Y())})
+			}
+			return coords, nil
+		}
+	}
+
+	wktString, err := wkt.Marshal(g)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling to WKT: %w", err)
+	}
+
+	return wktString, nil
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// scanOptions controls how scanPOI decodes a row.
+type scanOptions struct {
+	format geomFormat
+	// grouped rows were selected with groupedColumnList, so their geometry
+	// is a list of member points.
+	grouped bool
+}
+
+// groupedColumnList selects one row per POI id, for logical POIs (chains,
+// complexes) stored as several rows. The attributes come from the id's lowest
+// fid row, relying on SQLite taking bare columns from the row min() picks, and
+// every member geometry is hex-encoded into a comma-separated list. Use with
+// GROUP BY id.
+func groupedColumnList() string {
+	columns := make([]string, 0)
+	for _, name := range selectedColumns() {
+		switch name {
+		case "fid":
+			columns = append(columns, "min("+column("fid")+") AS "+column("fid"))
+		case "geom":
+			columns = append(columns, "group_concat(hex("+column("geom")+"), ',') AS "+column("geom"))
+		default:
+			columns = append(columns, column(name))
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// decodeGroupedGeometry decodes the geometry list from groupedColumnList into
+// a MultiPoint, or a Point for an id with a single row.
+func decodeGroupedGeometry(geoms []byte, format geomFormat) (any, int32, error) {
+	members := make([][]byte, 0)
+	for member := range strings.SplitSeq(string(geoms), ",") {
+		geomBytes, err := hex.DecodeString(member)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error decoding grouped geometry: %w", err)
+		}
+		members = append(members, geomBytes)
+	}
+
+	return decodeMultiPoint(members, format)
+}
+
+func parseGroupBy(value string) (bool, error) {
+	switch value {
+	case "":
+		return false, nil
+	case "id":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid group_by value '%s': must be 'id'", value)
+	}
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// H3 index bit layout (see https://h3geo.org/docs/library/index/cell):
+// bits 52-55 hold the resolution, followed by fifteen 3-bit digits, one per
+// resolution, with resolution 15 in the lowest bits. Unused digits are 7.
+const (
+	h3MaxResolution = 15
+	h3ResOffset     = 52
+	h3ResMask       = uint64(0xf) << h3ResOffset
+	h3DigitBits     = 3
+	h3DigitMask     = uint64(0x7)
+)
+
+func parseH3(cell string) (uint64, error) {
+	h, err := strconv.ParseUint(cell, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid H3 cell '%s'", cell)
+	}
+	return h, nil
+}
+
+func h3Resolution(h uint64) int {
+	return int((h & h3ResMask) >> h3ResOffset)
+}
+
+// h3Parent truncates an H3 cell to the given (coarser) resolution.
+func h3Parent(cell string, resolution int) (string, error) {
+	h, err := parseH3(cell)
+	if err != nil {
+		return "", err
+	}
+
+	if resolution > h3Resolution(h) {
+		return "", fmt.Errorf("resolution %d is finer than cell '%s'", resolution, cell)
+	}
+
+	h = (h &^ h3ResMask) | uint64(resolution)<<h3ResOffset
+	for r := resolution + 1; r <= h3MaxResolution; r++ {
+		h |= h3DigitMask << ((h3MaxResolution - r) * h3DigitBits)
+	}
+
+	return strconv.FormatUint(h, 16), nil
+}
+
+func parseResolution(str string) (int, error) {
+	resolution, err := strconv.Atoi(str)
+	if err != nil || resolution < 0 || resolution > h3MaxResolution {
+		return 0, fmt.Errorf("resolution must be an integer between 0 and %d", h3MaxResolution)
+	}
+	return resolution, nil
+}
+
+// h3ChildrenRange returns the first and last possible resolution 15
+// descendants of an H3 cell. Every descendant sorts between them, so stored
+// h3_15 values can be matched with a range comparison, without geometry.
+func h3ChildrenRange(cell string) (string, string, error) {
+	h, err := parseH3(cell)
+	if err != nil {
+		return "", "", err
+	}
+
+	resolution := h3Resolution(h)
+	first := (h &^ h3ResMask) | uint64(h3MaxResolution)<<h3ResOffset
+	last := first
+	for r := resolution + 1; r <= h3MaxResolution; r++ {
+		shift := (h3MaxResolution - r) * h3DigitBits
+		first &^= h3DigitMask << shift
+		// Digit 7 is unused below the cell's resolution, so 6 is the highest.
+		last = (last &^ (h3DigitMask << shift)) | uint64(6)<<shift
+	}
+
+	return strconv.FormatUint(first, 16), strconv.FormatUint(last, 16), nil
+}
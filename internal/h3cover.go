@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"math"
+	"slices"
+
+	"github.com/uber/h3-go"
+)
+
+// h3CellAreas are the average areas, in km², of the H3 cells at each
+// resolution (see https://h3geo.org/docs/core-library/restable).
+var h3CellAreas = [h3MaxResolution + 1]float64{
+	4357449.416078381, 609788.441794133, 86801.780398997, 12393.434655088,
+	1770.347654491, 252.903858182, 36.129062164, 5.161293360,
+	0.737327598, 0.105332513, 0.015047502, 0.002149643,
+	0.000307092, 0.000043870, 0.000006267, 0.000000895,
+}
+
+// earthRadius is the mean radius of the Earth in km.
+const earthRadius = 6371.0088
+
+// h3CoverEstimate approximates the number of cells at a resolution whose
+// centres lie in bbox, from its area, so that a bbox needing far too many can
+// be turned away before they are computed.
+func h3CoverEstimate(bbox []float64, resolution int) float64 {
+	area := earthRadius * earthRadius *
+		(bbox[RIGHT] - bbox[LEFT]) * math.Pi / 180 *
+		math.Abs(math.Sin(bbox[TOP]*math.Pi/180)-math.Sin(bbox[BOTTOM]*math.Pi/180))
+	return area / h3CellAreas[resolution]
+}
+
+// h3Cover returns, sorted, the H3 cells at a resolution intersecting bbox.
+// The cells whose centres lie inside it come from a polyfill; the rest
+// straddle its edge, and are found by walking the edge in steps of under
+// half a cell edge and checking the neighbours of the cells passed through
+// against the bbox, treating both as flat in degrees of latitude and
+// longitude.
+func h3Cover(bbox []float64, resolution int) []string {
+	corners := []h3.GeoCoord{
+		{Latitude: bbox[BOTTOM], Longitude: bbox[LEFT]},
+		{Latitude: bbox[BOTTOM], Longitude: bbox[RIGHT]},
+		{Latitude: bbox[TOP], Longitude: bbox[RIGHT]},
+		{Latitude: bbox[TOP], Longitude: bbox[LEFT]},
+	}
+
+	cells := make(map[h3.H3Index]struct{})
+	for _, cell := range h3.Polyfill(h3.GeoPolygon{Geofence: corners}, resolution) {
+		cells[cell] = struct{}{}
+	}
+
+	// A hexagon's edge is sqrt(2 × area / 3√3).
+	edge := math.Sqrt(2*h3CellAreas[resolution]/(3*math.Sqrt(3))) / earthRadius * 180 / math.Pi
+	latStep := edge / 2
+	longStep := latStep / math.Cos(max(math.Abs(bbox[TOP]), math.Abs(bbox[BOTTOM]))*math.Pi/180)
+
+	edgeCells := make(map[h3.H3Index]struct{})
+	for i, from := range corners {
+		to := corners[(i+1)%len(corners)]
+		steps := int(math.Ceil(max(
+			math.Abs(to.Latitude-from.Latitude)/latStep,
+			math.Abs(to.Longitude-from.Longitude)/longStep,
+		)))
+		for s := 0; s <= steps; s++ {
+			f := float64(s) / float64(max(steps, 1))
+			edgeCells[h3.FromGeo(h3.GeoCoord{
+				Latitude:  from.Latitude + (to.Latitude-from.Latitude)*f,
+				Longitude: from.Longitude + (to.Longitude-from.Longitude)*f,
+			}, resolution)] = struct{}{}
+		}
+	}
+
+	for edgeCell := range edgeCells {
+		for _, cell := range h3.KRing(edgeCell, 1) {
+			if _, ok := cells[cell]; !ok && cellIntersectsBBox(h3.ToGeoBoundary(cell), bbox) {
+				cells[cell] = struct{}{}
+			}
+		}
+	}
+
+	cover := make([]string, 0, len(cells))
+	for cell := range cells {
+		cover = append(cover, h3.ToString(cell))
+	}
+	slices.Sort(cover)
+	return cover
+}
+
+// cellIntersectsBBox reports whether a cell's boundary polygon overlaps
+// bbox: a vertex lies inside it, it holds a corner of the bbox, or their
+// edges cross.
+func cellIntersectsBBox(boundary h3.GeoBoundary, bbox []float64) bool {
+	inBBox := func(p h3.GeoCoord) bool {
+		return p.Longitude >= bbox[LEFT] && p.Longitude <= bbox[RIGHT] &&
+			p.Latitude >= bbox[BOTTOM] && p.Latitude <= bbox[TOP]
+	}
+	if slices.ContainsFunc(boundary, inBBox) {
+		return true
+	}
+
+	corners := [4][2]float64{
+		{bbox[LEFT], bbox[BOTTOM]}, {bbox[RIGHT], bbox[BOTTOM]},
+		{bbox[RIGHT], bbox[TOP]}, {bbox[LEFT], bbox[TOP]},
+	}
+	for _, corner := range corners {
+		if inPolygon(boundary, corner[0], corner[1]) {
+			return true
+		}
+	}
+
+	for i, a := range boundary {
+		b := boundary[(i+1)%len(boundary)]
+		for j, c := range corners {
+			d := corners[(j+1)%len(corners)]
+			if segmentsCross(a.Longitude, a.Latitude, b.Longitude, b.Latitude, c[0], c[1], d[0], d[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inPolygon reports whether (x, y) lies inside a polygon, by ray casting.
+func inPolygon(polygon h3.GeoBoundary, x, y float64) bool {
+	inside := false
+	for i, a := range polygon {
+		b := polygon[(i+len(polygon)-1)%len(polygon)]
+		if (a.Latitude > y) != (b.Latitude > y) &&
+			x < (b.Longitude-a.Longitude)*(y-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentsCross reports whether the segments (x1, y1)-(x2, y2) and
+// (x3, y3)-(x4, y4) intersect.
+func segmentsCross(x1, y1, x2, y2, x3, y3, x4, y4 float64) bool {
+	side := func(ax, ay, bx, by, cx, cy float64) float64 {
+		return (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+	}
+	d1, d2 := side(x3, y3, x4, y4, x1, y1), side(x3, y3, x4, y4, x2, y2)
+	d3, d4 := side(x1, y1, x2, y2, x3, y3), side(x1, y1, x2, y2, x4, y4)
+	return (d1 > 0) != (d2 > 0) && (d3 > 0) != (d4 > 0)
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
+
+// IconSize is a marker icon's dimensions in pixels, and the anchor point
+// (the pin tip) to place at the POI, measured from the top left.
+type IconSize struct {
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+	AnchorX int `json:"anchor_x"`
+	AnchorY int `json:"anchor_y"`
+}
+
+// iconSizes caches the size of each icon by file name, so PNG headers are
+// only decoded once. An icon that can't be decoded is cached as nil.
+var iconSizes = map[string]*IconSize{}
+var iconSizesMu sync.Mutex
+
+// iconAnchors overrides the default anchor, the bottom centre, by icon file.
+var iconAnchors = map[string][2]int{}
+
+// LoadIconSizes reads the anchor overrides from the JSON file at filename, if
+// set, as {"icon.png": [x, y]}, and decodes the dimensions of every mapped
+// icon up-front. Icons first mapped by a reload are decoded when first
+// listed.
+func LoadIconSizes(markers fs.FS, filename string) error {
+	if filename != "" {
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("error reading marker anchors: %w", err)
+		}
+		anchors := make(map[string][2]int)
+		if err := json.Unmarshal(contents, &anchors); err != nil {
+			return fmt.Errorf("error parsing marker anchors: %w", err)
+		}
+		iconAnchors = anchors
+		log.Printf("Loaded %d marker anchors from %s", len(anchors), filename)
+	}
+
+	decoded := make(map[string]struct{})
+	for _, icon := range currentIcons() {
+		if icon != "" && iconSize(markers, icon) != nil {
+			decoded[icon] = struct{}{}
+		}
+	}
+	log.Printf("Decoded the dimensions of %d marker icons", len(decoded))
+	return nil
+}
+
+// iconSize returns the size of an icon, or nil if it can't be decoded.
+func iconSize(markers fs.FS, icon string) *IconSize {
+	iconSizesMu.Lock()
+	defer iconSizesMu.Unlock()
+
+	if size, ok := iconSizes[icon]; ok {
+		return size
+	}
+
+	size, err := decodeIconSize(markers, icon)
+	if err != nil {
+		log.Printf("unable to read the dimensions of marker %s: %v", icon, err)
+	}
+	iconSizes[icon] = size
+	return size
+}
+
+func decodeIconSize(markers fs.FS, icon string) (*IconSize, error) {
+	f, err := markers.Open(icon)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("error closing marker %s: %v", icon, err)
+		}
+	}()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &IconSize{Width: config.Width, Height: config.Height, AnchorX: config.Width / 2, AnchorY: config.Height}
+	if anchor, ok := iconAnchors[icon]; ok {
+		size.AnchorX, size.AnchorY = anchor[0], anchor[1]
+	}
+	return size, nil
+}
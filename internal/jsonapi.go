@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const jsonAPIMediaType = "application/vnd.api+json"
+
+// JSONAPIResource is a single POI in JSON:API resource object form.
+type JSONAPIResource struct {
+	Type       string `json:"type"`
+	Id         string `json:"id"`
+	Attributes POI    `json:"attributes"`
+}
+
+type JSONAPIMeta struct {
+	Total        int           `json:"total"`
+	Clamped      bool          `json:"clamped,omitempty"`
+	SnappedBBox  []float64     `json:"snapped_bbox,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	ResultBounds *ResultBounds `json:"result_bounds,omitempty"`
+	Sampled      bool          `json:"sampled,omitempty"`
+	Attribution  []string      `json:"attribution"`
+}
+
+// JSONAPIResponse is the JSON:API document envelope for search results.
+type JSONAPIResponse struct {
+	Data []JSONAPIResource `json:"data"`
+	Meta JSONAPIMeta       `json:"meta"`
+}
+
+// wantsJSONAPI reports whether the client asked for a JSON:API document.
+func wantsJSONAPI(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), jsonAPIMediaType)
+}
+
+// toJSONAPI wraps search results in a JSON:API document. The total is the
+// number of POIs matched, which exceeds those returned if they were sampled
+// or paged.
+func toJSONAPI(resp SearchResponse) JSONAPIResponse {
+	data := make([]JSONAPIResource, 0, len(resp.Results))
+	for _, poi := range resp.Results {
+		data = append(data, JSONAPIResource{Type: "poi", Id: poi.Id, Attributes: poi})
+	}
+
+	total := max(len(resp.Results), resp.Total)
+	attribution := resp.Attribution
+	if resp.Meta != nil {
+		attribution = resp.Meta.Attribution
+	}
+
+	return JSONAPIResponse{
+		Data: data,
+		Meta: JSONAPIMeta{
+			Total:        total,
+			Clamped:      resp.Clamped,
+			SnappedBBox:  resp.SnappedBBox,
+			Warnings:     resp.Warnings,
+			ResultBounds: resp.ResultBounds,
+			Sampled:      resp.Sampled,
+			Attribution:  attribution,
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resultStream writes a search response's results as they are read, flushing
+// every flushRows POIs so that clients can render a large fetch
+// progressively. Without a Content-Length, the response is sent chunked.
+// Until the first POI is written, the handler is still free to respond with
+// an error instead.
+//
+// With a maxBytes budget, a POI that would take the results past it is not
+// written; truncated is set instead, and the handler should stop reading.
+type resultStream struct {
+	c         *gin.Context
+	flushRows int
+	rows      int
+	maxBytes  int
+	bytes     int
+	truncated bool
+}
+
+func newResultStream(c *gin.Context, flushRows int, maxBytes int) *resultStream {
+	return &resultStream{c: c, flushRows: flushRows, maxBytes: maxBytes}
+}
+
+// started reports whether any of the response has been written.
+func (s *resultStream) started() bool {
+	return s.rows > 0
+}
+
+func (s *resultStream) write(poi POI) error {
+	data, err := json.Marshal(poi)
+	if err != nil {
+		return fmt.Errorf("error encoding POI: %w", err)
+	}
+
+	if s.maxBytes > 0 && s.bytes+len(data)+1 > s.maxBytes {
+		s.truncated = true
+		return nil
+	}
+	s.bytes += len(data) + 1
+
+	w := s.c.Writer
+	if s.rows == 0 {
+		s.c.Header("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.WriteString(`{"results":[`)
+	} else {
+		_, err = w.WriteString(",")
+	}
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing POI: %w", err)
+	}
+
+	s.rows++
+	if s.rows%s.flushRows == 0 {
+		w.Flush()
+	}
+	return nil
+}
+
+// finish writes the fields following the results, or the whole response if
+// no POIs were written. A truncated stream ends with "truncated":true.
+func (s *resultStream) finish(resp SearchResponse) error {
+	resp.Truncated = s.truncated
+	if !s.started() {
+		resp.Results = []POI{}
+		s.c.JSON(http.StatusOK, resp)
+		return nil
+	}
+
+	// Results is the first field, so the rest of the response is whatever
+	// follows an empty results array.
+	resp.Results = []POI{}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("error encoding response: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte(`{"results":[]`))
+
+	if _, err := s.c.Writer.WriteString("]"); err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	if _, err := s.c.Writer.Write(data); err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	return nil
+}
+
+// truncatedHeader is set on a buffered search response whose results were
+// cut short by truncateResults; a streamed one has already sent its headers.
+const truncatedHeader = "X-Results-Truncated"
+
+// truncateResults returns the longest prefix of results whose JSON encoding,
+// comma-separated, fits in maxBytes, and whether any were dropped. The result
+// array dominates a response, so the few other fields aren't counted.
+func truncateResults(results []POI, maxBytes int) ([]POI, bool) {
+	size := 0
+	for i, poi := range results {
+		data, err := json.Marshal(poi)
+		if err != nil {
+			// Leave it for the response encoding to report.
+			return results, false
+		}
+
+		size += len(data) + 1
+		if size > maxBytes {
+			return results[:i], true
+		}
+	}
+	return results, false
+}
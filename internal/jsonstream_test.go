@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gatedWriter holds up the handler after each of its first gates flushes
+// until the client has acknowledged receiving the flushed data.
+type gatedWriter struct {
+	gin.ResponseWriter
+	gates   int
+	flushed chan<- struct{}
+	ack     <-chan struct{}
+}
+
+func (w *gatedWriter) Flush() {
+	w.ResponseWriter.Flush()
+	if w.gates == 0 {
+		return
+	}
+	w.gates--
+	w.flushed <- struct{}{}
+	select {
+	case <-w.ack:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func TestSearchStreamsChunks(t *testing.T) {
+	const chunks = 3
+
+	db := newTestDB(t)
+
+	flushed, ack := make(chan struct{}), make(chan struct{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", func(c *gin.Context) {
+		c.Writer = &gatedWriter{ResponseWriter: c.Writer, gates: chunks, flushed: flushed, ack: ack}
+	}, Search(db, SearchConfig{FlushRows: 1}))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/search?bbox=" + fixturesBBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if !slices.Equal(resp.TransferEncoding, []string{"chunked"}) {
+		t.Errorf("expected a chunked response, got transfer encoding %v", resp.TransferEncoding)
+	}
+
+	// Each flush must reach the client while the handler is still held up,
+	// so the body so far is never a complete document.
+	var body bytes.Buffer
+	buf := make([]byte, 64*1024)
+	for i := range chunks {
+		select {
+		case <-flushed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for flush %d", i+1)
+		}
+		n, err := resp.Body.Read(buf)
+		if n == 0 {
+			t.Fatalf("chunk %d: no data before the body completed: %v", i+1, err)
+		}
+		body.Write(buf[:n])
+		if json.Valid(body.Bytes()) {
+			t.Fatalf("chunk %d: body complete before the handler finished: %s", i+1, body.Bytes())
+		}
+		ack <- struct{}{}
+	}
+
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Write(rest)
+
+	var search SearchResponse
+	if err := json.Unmarshal(body.Bytes(), &search); err != nil {
+		t.Fatalf("invalid streamed response %s: %v", body.Bytes(), err)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7}; !slices.Equal(fids(search.Results), want) {
+		t.Errorf("streamed fids %v, want %v", fids(search.Results), want)
+	}
+}
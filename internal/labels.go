@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Labels holds human-readable category labels, keyed by language tag and
+// then by category.
+type Labels map[string]map[string]string
+
+// LoadLabels reads a JSON file of per-language category labels. A missing
+// file yields no labels, in which case labels are derived from the category
+// keys themselves.
+func LoadLabels(path string) (Labels, error) {
+	if path == "" {
+		return Labels{}, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("No category labels found at %s", path)
+		return Labels{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading category labels: %w", err)
+	}
+
+	var labels Labels
+	if err := json.Unmarshal(contents, &labels); err != nil {
+		return nil, fmt.Errorf("error parsing category labels: %w", err)
+	}
+
+	for lang := range labels {
+		if _, err := language.Parse(lang); err != nil {
+			return nil, fmt.Errorf("invalid language tag in category labels: %s", lang)
+		}
+	}
+
+	log.Printf("Loaded category labels for %d languages from %s", len(labels), path)
+	return labels, nil
+}
+
+// localizer resolves category labels for the best language match of an
+// Accept-Language header, falling back to English and then to a label
+// derived from the category key.
+type localizer struct {
+	langs   []string
+	matcher language.Matcher
+	labels  Labels
+}
+
+func newLocalizer(labels Labels) *localizer {
+	// English goes first so it is the matcher's default.
+	langs := []string{"en"}
+	tags := []language.Tag{language.English}
+	for lang := range labels {
+		if tag := language.Make(lang); tag != language.English {
+			langs = append(langs, lang)
+			tags = append(tags, tag)
+		}
+	}
+
+	return &localizer{langs: langs, matcher: language.NewMatcher(tags), labels: labels}
+}
+
+// lookup returns the language key in labels best matching acceptLanguage.
+func (l *localizer) lookup(acceptLanguage string) string {
+	accepted, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(accepted) == 0 {
+		return "en"
+	}
+
+	_, index, _ := l.matcher.Match(accepted...)
+	return l.langs[index]
+}
+
+func (l *localizer) label(lang string, category string) string {
+	if label, ok := l.labels[lang][category]; ok {
+		return label
+	}
+	if label, ok := l.labels["en"][category]; ok {
+		return label
+	}
+	return humanize(category)
+}
+
+// labelsFor returns the label for every category in the best language match.
+func (l *localizer) labelsFor(acceptLanguage string, categories map[string]int) map[string]string {
+	lang := l.lookup(acceptLanguage)
+	result := make(map[string]string, len(categories))
+	for category := range categories {
+		result[category] = l.label(lang, category)
+	}
+	return result
+}
+
+// humanize turns a category key such as "coffee_shop" into "Coffee shop".
+func humanize(category string) string {
+	s := strings.ReplaceAll(category, "_", " ")
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// LoadLSOANames reads a CSV lookup of LSOA 2021 codes to names, such as the
+// ONS "LSOA (2021) Names and Codes" file. The code and name are taken from
+// the LSOA21CD and LSOA21NM columns if there's a header naming them, or else
+// the first two columns. A missing file yields no lookup.
+func LoadLSOANames(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		log.Printf("No LSOA names found at %s", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading LSOA names: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("error closing LSOA names: %v", err)
+		}
+	}()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	codeCol, nameCol := 0, 1
+	names := make(map[string]string)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing LSOA names: %w", err)
+		}
+
+		if line == 1 {
+			header := make([]string, len(record))
+			for i, name := range record {
+				header[i] = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			}
+			if code, name := slices.Index(header, "LSOA21CD"), slices.Index(header, "LSOA21NM"); code >= 0 && name >= 0 {
+				codeCol, nameCol = code, name
+				continue
+			}
+		}
+
+		if len(record) <= max(codeCol, nameCol) {
+			return nil, fmt.Errorf("error parsing LSOA names: line %d has too few columns", line)
+		}
+		names[strings.TrimSpace(record[codeCol])] = strings.TrimSpace(record[nameCol])
+	}
+
+	log.Printf("Loaded %d LSOA names from %s", len(names), path)
+	return names, nil
+}
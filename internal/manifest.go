@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ManifestEntry struct {
+	Category         string   `json:"category"`
+	Icon             string   `json:"icon"`
+	AvailableFormats []string `json:"available_formats"`
+	Has2x            bool     `json:"has_2x"`
+	// IconSize is omitted if the icon's dimensions couldn't be read.
+	*IconSize
+}
+
+var markerFormats = []string{"png", "webp", "svg"}
+
+func MarkersManifest(markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		icons := currentIcons()
+		categories := make([]string, 0, len(icons))
+		for category, icon := range icons {
+			if icon != "" {
+				categories = append(categories, category)
+			}
+		}
+		sort.Strings(categories)
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+
+		enc := json.NewEncoder(c.Writer)
+		for _, category := range categories {
+			if err := enc.Encode(manifestEntry(markers, category, icons[category])); err != nil {
+				log.Printf("error writing manifest entry: %v", err)
+				return
+			}
+		}
+	}
+}
+
+func manifestEntry(markers fs.FS, category string, icon string) ManifestEntry {
+	base := strings.TrimSuffix(icon, path.Ext(icon))
+
+	formats := make([]string, 0, len(markerFormats))
+	for _, format := range markerFormats {
+		if exists(markers, base+"."+format) {
+			formats = append(formats, format)
+		}
+	}
+
+	return ManifestEntry{
+		Category:         category,
+		Icon:             icon,
+		AvailableFormats: formats,
+		Has2x:            exists(markers, base+"@2x"+path.Ext(icon)),
+		IconSize:         iconSize(markers, icon),
+	}
+}
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+type MarkerMetaResponse struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Anchor is the [x, y] of the pin tip from the top left, for Leaflet's
+	// iconAnchor; omitted, like the dimensions, if the icon can't be read.
+	Anchor    []int    `json:"anchor,omitempty"`
+	ShadowURL string   `json:"shadow_url"`
+	Has2x     bool     `json:"has_2x"`
+	Formats   []string `json:"formats"`
+}
+
+// MarkerMeta describes a single category's marker, with everything needed to
+// configure a Leaflet icon for it: the manifest entry for one category.
+func MarkerMeta(markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		category := c.Param("category")
+		icon, exists := currentIcons()[category]
+		if icon == "" || !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+			return
+		}
+
+		entry := manifestEntry(markers, category, icon)
+		resp := MarkerMetaResponse{
+			URL:       markerURL(category),
+			ShadowURL: "/v1/geods-poi/marker/shadow",
+			Has2x:     entry.Has2x,
+			Formats:   entry.AvailableFormats,
+		}
+		if entry.IconSize != nil {
+			resp.Width, resp.Height = entry.Width, entry.Height
+			resp.Anchor = []int{entry.AnchorX, entry.AnchorY}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
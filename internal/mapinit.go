@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MapInitResponse bundles everything the map needs on a cold start.
+type MapInitResponse struct {
+	// Results are the POIs in the bbox, exactly as returned by Search.
+	Results []POI `json:"results"`
+	// Facets counts the results by category, main and alternate alike.
+	Facets map[string]int `json:"facets"`
+	// Bounds is the extent of the whole dataset, as in ref-data.
+	Bounds       []float64     `json:"bounds,omitempty"`
+	Clamped      bool          `json:"clamped,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	ResultBounds *ResultBounds `json:"result_bounds,omitempty"`
+	Sampled      bool          `json:"sampled,omitempty"`
+	Total        int           `json:"total,omitempty"`
+	Attribution  []string      `json:"attribution"`
+	Meta         *SearchMeta   `json:"meta,omitempty"`
+}
+
+// MapInit runs the search handler for the request and adds per-category facet
+// counts and the dataset bounds, saving the map two round-trips on load. It
+// accepts every Search parameter but format and shape, since facets need the
+// full results array; errors from Search are passed straight on.
+func MapInit(search gin.HandlerFunc, summaries *SummaryCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, param := range []string{"format", "shape"} {
+			if c.Query(param) != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " is not supported by map-init"})
+				return
+			}
+		}
+
+		original := c.Writer
+		capture := &captureWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = capture
+		c.Request.Header.Set("Accept", "application/json")
+		search(c)
+		c.Writer = original
+
+		if capture.status != http.StatusOK {
+			c.Data(capture.status, "application/json; charset=utf-8", capture.body.Bytes())
+			return
+		}
+
+		var resp SearchResponse
+		if err := json.Unmarshal(capture.body.Bytes(), &resp); err != nil {
+			log.Printf("error decoding search response: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		results := resp.Results
+		if results == nil {
+			results = []POI{}
+		}
+
+		facets := make(map[string]int)
+		for _, poi := range results {
+			for _, category := range poi.Categories {
+				facets[category]++
+			}
+		}
+
+		summary, _ := summaries.Get()
+		c.JSON(http.StatusOK, MapInitResponse{
+			Results:      results,
+			Facets:       facets,
+			Bounds:       summary.Bounds,
+			Clamped:      resp.Clamped,
+			Warnings:     resp.Warnings,
+			ResultBounds: resp.ResultBounds,
+			Sampled:      resp.Sampled,
+			Total:        resp.Total,
+			Attribution:  resp.Attribution,
+			Meta:         resp.Meta,
+		})
+	}
+}
+
+// captureWriter buffers a handler's response body and status instead of
+// sending them, so the output can be post-processed.
+type captureWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *captureWriter) WriteHeaderNow() {}
+
+// Flush is a no-op, as nothing is sent until the handler has finished.
+func (w *captureWriter) Flush() {}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ColumnMapping maps the logical POI table and column names used throughout
+// the API onto the physical names in the database, so differently-named
+// schemas can be served without code changes.
+type ColumnMapping struct {
+	Table   string            `json:"table"`
+	Columns map[string]string `json:"columns"`
+}
+
+var mapping = defaultMapping()
+
+func defaultMapping() ColumnMapping {
+	columns := make(map[string]string, len(poiColumns)+len(optionalColumns))
+	for _, name := range append(slices.Clone(poiColumns), optionalColumns...) {
+		columns[name] = name
+	}
+	return ColumnMapping{Table: "poi_uk", Columns: columns}
+}
+
+// LoadColumnMapping overrides the default table and column names from a JSON
+// file. Columns not mentioned in the file keep their default names.
+func LoadColumnMapping(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading column mapping: %w", err)
+	}
+
+	var overrides ColumnMapping
+	if err := json.Unmarshal(contents, &overrides); err != nil {
+		return fmt.Errorf("error parsing column mapping: %w", err)
+	}
+
+	m := defaultMapping()
+	if overrides.Table != "" {
+		m.Table = overrides.Table
+	}
+	for name, physical := range overrides.Columns {
+		if _, ok := m.Columns[name]; !ok {
+			return fmt.Errorf("unknown column in mapping: %s", name)
+		}
+		if physical == "" {
+			return fmt.Errorf("empty mapping for column: %s", name)
+		}
+		m.Columns[name] = physical
+	}
+
+	mapping = m
+	log.Printf("Loaded column mapping from %s (table: %s)", path, m.Table)
+	return nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// table returns the quoted physical name of the POI table.
+func table() string {
+	return quoteIdent(mapping.Table)
+}
+
+// column returns the quoted physical name of a logical POI column.
+func column(name string) string {
+	physical, ok := mapping.Columns[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown logical column: %s", name))
+	}
+	return quoteIdent(physical)
+}
+
+// columnList returns a comma-separated SELECT list of logical POI columns.
+func columnList(names ...string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = column(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -5,12 +5,23 @@ import (
 
 	_ "embed"
 	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
 	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
 //go:embed _mappings.gemini2.5_pro.json
 var mappingsFileContents []byte
+
+// icons maps categories onto marker icon files. Reloads swap the whole map
+// under iconsMu, so a map returned by currentIcons must never be modified.
 var icons map[string]string
+var iconsMu sync.RWMutex
 
 func init() {
 	err := json.Unmarshal(mappingsFileContents, &icons)
@@ -19,24 +30,110 @@ func init() {
 	}
 }
 
-func Marker(c *gin.Context) {
-	category := c.Param("category")
-	if category == "" {
-		c.JSON(400, gin.H{"error": "category is required"})
+// currentIcons returns the category to icon mappings in effect.
+func currentIcons() map[string]string {
+	iconsMu.RLock()
+	defer iconsMu.RUnlock()
+	return icons
+}
+
+// overlayFS serves files from primary, falling back to fallback for any
+// file that does not exist in primary.
+type overlayFS struct {
+	primary  fs.FS
+	fallback fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.primary.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Open(name)
+	}
+	return f, err
+}
+
+// MarkersFS returns the filesystem markers are served from: the embedded
+// icons, optionally overridden by files found in dir on disk.
+func MarkersFS(embedded fs.FS, dir string) (fs.FS, error) {
+	markers, err := fs.Sub(embedded, "markers")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		return markers, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Serving markers from %s, falling back to embedded icons", dir)
+	return overlayFS{primary: os.DirFS(dir), fallback: markers}, nil
+}
+
+func Marker(markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		category := c.Param("category")
+		if category == "" {
+			c.JSON(400, gin.H{"error": "category is required"})
+			return
+		}
+
+		icon, exists := currentIcons()[category]
+		if icon == "" || !exists {
+			c.JSON(404, gin.H{"error": "category not found"})
+			return
+		}
+
+		serveMarker(c, markers, icon)
+	}
+}
+
+func Shadow(markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serveMarker(c, markers, "_shadow.png")
+	}
+}
+
+// startTime stands in for the modification time of embedded markers, which
+// have none, so they're revalidated against the running binary.
+var startTime = time.Now()
+
+// serveMarker serves a marker icon with a Last-Modified header taken from the
+// file, answering If-Modified-Since with 304 Not Modified.
+func serveMarker(c *gin.Context, markers fs.FS, name string) {
+	f, err := markers.Open(name)
+	if err != nil {
+		log.Printf("error opening marker %s: %v", name, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "marker not found"})
 		return
 	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("error closing marker %s: %v", name, err)
+		}
+	}()
 
-	icon, exists := icons[category]
-	if icon == "" || !exists {
-		c.JSON(404, gin.H{"error": "category not found"})
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("error reading marker %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
 		return
 	}
 
-	c.Header("Content-Type", "image/png")
-	c.File("./data/markers/" + icon)
-}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		log.Printf("marker %s is not seekable", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+
+	modTime := info.ModTime()
+	if modTime.IsZero() {
+		modTime = startTime
+	}
 
-func Shadow(c *gin.Context) {
 	c.Header("Content-Type", "image/png")
-	c.File("./data/markers/_shadow.png")
+	http.ServeContent(c.Writer, c.Request, name, modTime, content)
 }
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServeMarkerConditionalGet(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(dir, "pub.png")
+	if err := os.WriteFile(path, []byte("\x89PNG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		ifModifiedSince time.Time
+		wantStatus      int
+	}{
+		{"unconditional", time.Time{}, http.StatusOK},
+		{"not modified since", modTime, http.StatusNotModified},
+		{"modified since", modTime.Add(-time.Hour), http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveTestMarker(os.DirFS(dir), "pub.png", tc.ifModifiedSince)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if got, want := w.Header().Get("Last-Modified"), modTime.Format(http.TimeFormat); got != want {
+				t.Errorf("Last-Modified %q, want %q", got, want)
+			}
+			if tc.wantStatus == http.StatusNotModified && w.Body.Len() > 0 {
+				t.Errorf("304 response has a body: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestServeMarkerWithoutModTime(t *testing.T) {
+	markers := fstest.MapFS{"pub.png": {Data: []byte("\x89PNG")}}
+
+	w := serveTestMarker(markers, "pub.png", time.Time{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	lastModified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	if err != nil || !lastModified.Equal(startTime.Truncate(time.Second)) {
+		t.Fatalf("Last-Modified %q, want the start time %s", w.Header().Get("Last-Modified"), startTime.UTC().Format(http.TimeFormat))
+	}
+
+	if w := serveTestMarker(markers, "pub.png", lastModified); w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestServeMarkerNotFound(t *testing.T) {
+	if w := serveTestMarker(fstest.MapFS{}, "pub.png", time.Time{}); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func serveTestMarker(markers fs.FS, name string, ifModifiedSince time.Time) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/markers/:name", func(c *gin.Context) { serveMarker(c, markers, c.Param("name")) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/markers/"+name, nil)
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
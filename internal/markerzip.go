@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MarkersZip streams a ZIP archive of every mapped marker icon, the shadow
+// and the category mappings, for apps that bundle icons offline. The archive
+// is written directly to the response rather than buffered, so icons are
+// checked for before it starts: any that are missing are left out, and
+// logged, rather than cutting the archive short after its 200.
+func MarkersZip(markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		icons := currentIcons()
+		files := []string{"_shadow.png"}
+		for _, icon := range icons {
+			if icon != "" && !slices.Contains(files, icon) {
+				files = append(files, icon)
+			}
+		}
+		slices.Sort(files)
+		files = slices.DeleteFunc(files, func(name string) bool {
+			if _, err := fs.Stat(markers, name); err != nil {
+				log.Printf("WARNING: leaving marker %s out of the zip: %v", name, err)
+				return true
+			}
+			return false
+		})
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="markers.zip"`)
+		c.Status(http.StatusOK)
+
+		zw := zip.NewWriter(c.Writer)
+		defer func() {
+			if err := zw.Close(); err != nil {
+				log.Printf("error closing zip: %v", err)
+			}
+		}()
+
+		mappings, err := json.MarshalIndent(icons, "", "  ")
+		if err != nil {
+			log.Printf("error marshaling mappings: %v", err)
+			return
+		}
+		w, err := zw.Create("mappings.json")
+		if err != nil {
+			log.Printf("error adding mappings to zip: %v", err)
+			return
+		}
+		if _, err := w.Write(mappings); err != nil {
+			log.Printf("error writing mappings to zip: %v", err)
+			return
+		}
+
+		for _, name := range files {
+			if err := addToZip(zw, markers, name); err != nil {
+				log.Printf("error adding %s to zip: %v", name, err)
+				return
+			}
+		}
+	}
+}
+
+func addToZip(zw *zip.Writer, fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("error closing %s: %v", name, err)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = "markers/" + name
+	// PNGs are already compressed, so deflating them again gains nothing.
+	header.Method = zip.Store
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMarkersZipSkipsMissingIcons(t *testing.T) {
+	iconsMu.Lock()
+	previous := icons
+	icons = map[string]string{"pub": "pub.png", "cafe": "cafe.png", "bench": "missing.png", "unknown": ""}
+	iconsMu.Unlock()
+	t.Cleanup(func() {
+		iconsMu.Lock()
+		icons = previous
+		iconsMu.Unlock()
+	})
+
+	markers := fstest.MapFS{
+		"_shadow.png": {Data: []byte("shadow")},
+		"pub.png":     {Data: []byte("pub")},
+		"cafe.png":    {Data: []byte("cafe")},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/markers.zip", MarkersZip(markers))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/markers.zip", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{"mappings.json", "markers/_shadow.png", "markers/cafe.png", "markers/pub.png"}
+	if !slices.Equal(names, want) {
+		t.Errorf("zip holds %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const msgpackMediaType = "application/msgpack"
+
+// wantsMsgpack reports whether the client asked for a MessagePack body.
+func wantsMsgpack(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return strings.Contains(accept, msgpackMediaType) || strings.Contains(accept, "application/x-msgpack")
+}
+
+// renderMsgpack writes v as MessagePack. Struct fields are keyed by their json
+// tags so the binary and JSON shapes carry identical field names.
+func renderMsgpack(c *gin.Context, code int, v any) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(v); err != nil {
+		log.Printf("error encoding msgpack: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+
+	c.Data(code, msgpackMediaType, buf.Bytes())
+}
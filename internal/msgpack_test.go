@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestSearchMsgpackRoundTrip(t *testing.T) {
+	r := newTestSearch(t, SearchConfig{})
+
+	for _, query := range []string{
+		"bbox=" + fixturesBBox,
+		"bbox=" + fixturesBBox + "&shape=map",
+	} {
+		t.Run(query, func(t *testing.T) {
+			get := func(accept string) []byte {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/search?"+query, nil)
+				req.Header.Set("Accept", accept)
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("Accept: %s: expected status %d, got %d: %s", accept, http.StatusOK, w.Code, w.Body)
+				}
+				if got := w.Header().Get("Content-Type"); got == "" || (accept == msgpackMediaType) != (got == msgpackMediaType) {
+					t.Fatalf("Accept: %s: unexpected Content-Type %s", accept, got)
+				}
+				return w.Body.Bytes()
+			}
+
+			var fromJSON, fromMsgpack map[string]any
+			if err := json.Unmarshal(get("application/json"), &fromJSON); err != nil {
+				t.Fatal(err)
+			}
+			dec := msgpack.NewDecoder(bytes.NewReader(get(msgpackMediaType)))
+			dec.SetCustomStructTag("json")
+			if err := dec.Decode(&fromMsgpack); err != nil {
+				t.Fatal(err)
+			}
+
+			assertSameFields(t, "response", fromJSON, fromMsgpack)
+		})
+	}
+}
+
+// assertSameFields compares decoded JSON and MessagePack documents, which
+// must have the same keys at every level and equal values, numbers compared
+// as float64.
+func assertSameFields(t *testing.T, path string, want, got any) {
+	t.Helper()
+
+	switch want := want.(type) {
+	case map[string]any:
+		object, ok := got.(map[string]any)
+		if !ok {
+			t.Errorf("%s: MessagePack has %T, JSON an object", path, got)
+			return
+		}
+		if w, g := slices.Sorted(maps.Keys(want)), slices.Sorted(maps.Keys(object)); !slices.Equal(w, g) {
+			t.Errorf("%s: MessagePack fields %v, JSON fields %v", path, g, w)
+			return
+		}
+		for key := range want {
+			assertSameFields(t, path+"."+key, want[key], object[key])
+		}
+	case []any:
+		array, ok := got.([]any)
+		if !ok || len(array) != len(want) {
+			t.Errorf("%s: MessagePack has %v, JSON %v", path, got, want)
+			return
+		}
+		for i := range want {
+			assertSameFields(t, path+"[]", want[i], array[i])
+		}
+	case float64:
+		if number := reflect.ValueOf(got); !number.CanConvert(reflect.TypeFor[float64]()) ||
+			number.Convert(reflect.TypeFor[float64]()).Float() != want {
+			t.Errorf("%s: MessagePack has %v, JSON %v", path, got, want)
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: MessagePack has %v, JSON %v", path, got, want)
+		}
+	}
+}
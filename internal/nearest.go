@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"geods-poi-api/internal/geo"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultNearestLimit = 10
+	maxNearestLimit     = 100
+
+	// The nearest search starts with a ring of nearestStartRadius metres and
+	// doubles it up to nearestMaxRings times, so the last ring is 512km:
+	// enough to reach across the UK from anywhere in it.
+	nearestStartRadius = 250
+	nearestMaxRings    = 12
+)
+
+// Nearest returns the n POIs nearest to a point, closest first, with their
+// distance in metres.
+//
+// Rather than scanning one box large enough for the sparsest areas, it
+// searches rings of doubling radius: each ring queries the box enclosing its
+// circle (through the R-tree index, where bboxClause uses it) and stops once
+// n POIs lie within the circle, since no POI outside the circle can be nearer
+// than those. In dense areas the first small ring suffices, and each larger
+// ring costs about four times the last, so re-reading the inner rings adds
+// only a third. After nearestMaxRings rings, whatever lies within the last
+// circle is returned, which may be fewer than n.
+func Nearest(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		lat, err := parseCoordinate("lat", c.Query("lat"), 90)
+		errs.add("lat", err)
+		long, err := parseCoordinate("long", c.Query("long"), 180)
+		errs.add("long", err)
+		n, err := parseLimit("n", c.Query("n"))
+		errs.add("n", err)
+		categories, err := parseCategories(c.Query("categories"))
+		errs.add("categories", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		if n == 0 {
+			n = defaultNearestLimit
+		}
+		n = min(n, maxNearestLimit)
+
+		var results []POI
+		radius := float64(nearestStartRadius)
+		for ring := 1; ; ring++ {
+			results, err = nearestWithin(c.Request.Context(), db, lat, long, radius, categories)
+			if err != nil {
+				log.Printf("error querying database: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			if len(results) >= n || ring == nearestMaxRings {
+				break
+			}
+			radius *= 2
+		}
+
+		slices.SortFunc(results, func(a, b POI) int {
+			return cmp.Or(cmp.Compare(*a.Distance, *b.Distance), cmp.Compare(a.Fid, b.Fid))
+		})
+		if len(results) > n {
+			results = results[:n]
+		}
+
+		c.JSON(http.StatusOK, SearchResponse{
+			Results:     results,
+			Attribution: ATTRIBUTION,
+		})
+	}
+}
+
+// nearestWithin returns the POIs within radius metres of (lat, long), with
+// their distances, in no particular order.
+func nearestWithin(ctx context.Context, db *sql.DB, lat, long, radius float64, categories map[string]struct{}) ([]POI, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+columnList(selectedColumns()...)+" FROM "+table()+" WHERE "+bboxClause(),
+		bboxArgs(geo.BBoxFromRadius(lat, long, radius))...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	results := make([]POI, 0)
+	for rows.Next() {
+		poi, err := scanPOI(rows, scanOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		if len(categories) > 0 && !hasCategoryMatch(poi.Categories, categories) {
+			continue
+		}
+
+		distance := geo.Haversine(lat, long, float64(poi.Lat), float64(poi.Long))
+		if distance <= radius {
+			distance = math.Round(distance*10) / 10
+			poi.Distance = &distance
+			results = append(results, poi)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return results, nil
+}
+
+// parseCoordinate parses a required latitude or longitude, which must lie
+// within ±limit degrees.
+func parseCoordinate(name string, value string, limit float64) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+
+	coord, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(coord) || math.Abs(coord) > limit {
+		return 0, fmt.Errorf("invalid %s value '%s': must be a number between -%g and %g", name, value, limit, limit)
+	}
+
+	return coord, nil
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoRoute answers requests for paths no route matches in the API's JSON error
+// shape, rather than gin's plain text default.
+func NoRoute(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "No such endpoint: " + c.Request.URL.Path,
+		"code":  "not_found",
+	})
+}
+
+// NoMethod answers requests to a route with a method it doesn't support; gin
+// has already listed the methods it does in the Allow header.
+func NoMethod(c *gin.Context) {
+	c.JSON(http.StatusMethodNotAllowed, gin.H{
+		"error": c.Request.Method + " is not supported by " + c.Request.URL.Path,
+		"code":  "method_not_allowed",
+	})
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultOverviewCells = 16
+	maxOverviewCells     = 256
+)
+
+type OverviewResponse struct {
+	BBox []float64 `json:"bbox"`
+	// Counts holds a row of cols counts for each of the rows, northernmost
+	// first, so that it reads like the map it overlays.
+	Counts [][]int `json:"counts"`
+	// LatEdges are the rows+1 latitudes bounding the rows, north to south,
+	// and LongEdges the cols+1 longitudes bounding the columns, west to
+	// east: row i, column j spans LatEdges[i+1]..LatEdges[i] by
+	// LongEdges[j]..LongEdges[j+1].
+	LatEdges    []Coordinate `json:"lat_edges"`
+	LongEdges   []Coordinate `json:"long_edges"`
+	Total       int          `json:"total"`
+	Attribution []string     `json:"attribution"`
+}
+
+// Overview divides a bbox into a grid of cols by rows cells and counts the
+// POIs in each, giving a density overview without fetching the points. The
+// cell of each POI is computed in SQL, so only the non-empty cells' counts
+// leave the database. POIs on the bbox's east or south edge are counted in
+// the last column or row, and any the R-tree's rounded bounds let slightly
+// outside it in the nearest cell.
+func Overview(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		bbox, err := parseAreaBBox(c.Query("bbox"))
+		errs.add("bbox", err)
+		cols, err := parseOverviewCells("cols", c.Query("cols"))
+		errs.add("cols", err)
+		rows, err := parseOverviewCells("rows", c.Query("rows"))
+		errs.add("rows", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		colScale := float64(cols) / (bbox[RIGHT] - bbox[LEFT])
+		rowScale := float64(rows) / (bbox[TOP] - bbox[BOTTOM])
+		query := "SELECT" +
+			" MAX(MIN(CAST((" + column("long") + " - ?) * ? AS INTEGER), ?), 0) AS col," +
+			" MAX(MIN(CAST((? - " + column("lat") + ") * ? AS INTEGER), ?), 0) AS row," +
+			" COUNT(*)" +
+			" FROM " + table() + " WHERE " + bboxClause() + " GROUP BY col, row"
+		args := append([]any{bbox[LEFT], colScale, cols - 1, bbox[TOP], rowScale, rows - 1}, bboxArgs(bbox)...)
+
+		cells, err := db.QueryContext(c.Request.Context(), query, args...)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := cells.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		counts := make([][]int, rows)
+		for i := range counts {
+			counts[i] = make([]int, cols)
+		}
+
+		total := 0
+		for cells.Next() {
+			var col, row, count int
+			if err := cells.Scan(&col, &row, &count); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			counts[row][col] = count
+			total += count
+		}
+		if err = cells.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, OverviewResponse{
+			BBox:        bbox,
+			Counts:      counts,
+			LatEdges:    gridEdges(bbox[TOP], bbox[BOTTOM], rows),
+			LongEdges:   gridEdges(bbox[LEFT], bbox[RIGHT], cols),
+			Total:       total,
+			Attribution: ATTRIBUTION,
+		})
+	}
+}
+
+// gridEdges returns the n+1 values dividing from..to into n equal steps.
+func gridEdges(from, to float64, n int) []Coordinate {
+	edges := make([]Coordinate, n+1)
+	for i := range edges {
+		edges[i] = Coordinate(from + (to-from)*float64(i)/float64(n))
+	}
+	edges[n] = Coordinate(to)
+	return edges
+}
+
+// parseAreaBBox parses a bbox, which must have a non-zero width and
+// height to be divided into cells.
+func parseAreaBBox(value string) ([]float64, error) {
+	bbox, err := parseBBox(value)
+	if err != nil {
+		return nil, err
+	}
+	if bbox[RIGHT] <= bbox[LEFT] || bbox[TOP] <= bbox[BOTTOM] {
+		return nil, fmt.Errorf("bbox must be given as left,bottom,right,top with right > left and top > bottom")
+	}
+	return bbox, nil
+}
+
+func parseOverviewCells(name string, value string) (int, error) {
+	if value == "" {
+		return defaultOverviewCells, nil
+	}
+
+	cells, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || cells < 1 || cells > maxOverviewCells {
+		return 0, fmt.Errorf("invalid %s value '%s': must be an integer between 1 and %d", name, value, maxOverviewCells)
+	}
+
+	return cells, nil
+}
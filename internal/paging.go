@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kofalt/go-memoize"
+)
+
+// countCache remembers the total matches for each filter set, so paging
+// through a result set runs COUNT(*) once rather than for every page. Entries
+// are keyed on the table's last_change, so stale totals are never served once
+// the data changes.
+type countCache struct {
+	db    *sql.DB
+	cache *memoize.Memoizer
+}
+
+func newCountCache(db *sql.DB) *countCache {
+	return &countCache{db: db, cache: TrackCache("counts", memoize.NewMemoizer(10*time.Minute, time.Hour))}
+}
+
+// count returns the number of rows matching the WHERE clause where.
+func (cc *countCache) count(ctx context.Context, where string, args []any) (int, error) {
+	key := fmt.Sprintf("%s\x00%s\x00%v", lastChange(ctx, cc.db), where, args)
+	total, err, _ := memoize.Call(cc.cache, key, func() (int, error) {
+		var total int
+		err := cc.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table()+" WHERE "+where, args...).Scan(&total)
+		return total, err
+	})
+	return total, err
+}
+
+func parseOffset(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset value '%s': must be a non-negative integer", value)
+	}
+
+	return offset, nil
+}
+
+// paginate returns the page of pois starting at offset, of at most limit.
+func paginate(pois []POI, offset int, limit int) []POI {
+	if offset >= len(pois) {
+		return []POI{}
+	}
+	return pois[offset:min(offset+limit, len(pois))]
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPoints caps a ?format=points response. Larger result sets are thinned
+// to it, as with ?sample=N.
+const maxPoints = 100_000
+
+// point is a POI reduced to what a heatmap needs: its coordinates, and its
+// H3 cell for thinning.
+type point struct {
+	coords [2]Coordinate
+	h3     string
+}
+
+// parseFormat parses the search format parameter; the only alternative to
+// the default, full results, is "points".
+func parseFormat(value string) (bool, error) {
+	switch value {
+	case "":
+		return false, nil
+	case "points":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid format value '%s': must be points", value)
+	}
+}
+
+// respondPoints writes a ?format=points response, as MessagePack when the
+// client negotiated for it.
+func respondPoints(c *gin.Context, points [][2]Coordinate) {
+	if wantsMsgpack(c) {
+		renderMsgpack(c, http.StatusOK, points)
+		return
+	}
+	c.JSON(http.StatusOK, points)
+}
+
+// searchPoints returns the [long, lat] of each POI matching where, reading
+// only the columns that needs rather than scanning full POIs. If keep is
+// non-nil, only POIs whose categories it accepts are returned. The points are
+// thinned to at most limit.
+func searchPoints(ctx context.Context, db *sql.DB, where string, args []any, keep func([]string) bool, limit int) ([][2]Coordinate, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+columnList("long", "lat", "h3_15", "main_category", "alternate_category")+" FROM "+table()+
+			" WHERE "+where+" ORDER BY "+column("fid"),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	points := make([]point, 0)
+	var long, lat sql.NullFloat64
+	var mainCategory, alternateCategory sql.NullString
+	for rows.Next() {
+		var p point
+		if err := rows.Scan(&long, &lat, &p.h3, &mainCategory, &alternateCategory); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if !long.Valid || !lat.Valid {
+			continue
+		}
+		if keep != nil && !keep(splitCategories(mainCategory, alternateCategory)) {
+			continue
+		}
+
+		p.coords = [2]Coordinate{Coordinate(long.Float64), Coordinate(lat.Float64)}
+		points = append(points, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	if len(points) > limit {
+		points = sampleByCell(points, limit, func(p point) string { return p.h3 })
+	}
+
+	coords := make([][2]Coordinate, len(points))
+	for i, p := range points {
+		coords[i] = p.coords
+	}
+	return coords, nil
+}
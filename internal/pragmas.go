@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDriver is the database/sql driver name that applies the configured
+// PRAGMAs to every new connection.
+const SQLiteDriver = "sqlite3_pragmas"
+
+// tunablePragmas are the PRAGMAs that may be set at startup.
+var tunablePragmas = []string{"cache_size", "mmap_size", "temp_store", "journal_mode"}
+
+var pragmaValue = regexp.MustCompile(`^-?[A-Za-z0-9_]+$`)
+
+// ParsePragmas parses name=value settings for the tunable PRAGMAs.
+func ParsePragmas(settings []string) (map[string]string, error) {
+	pragmas := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		name, value, ok := strings.Cut(setting, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !slices.Contains(tunablePragmas, name) {
+			return nil, fmt.Errorf("invalid pragma '%s': expected name=value, where name is one of %s", setting, strings.Join(tunablePragmas, ", "))
+		}
+		if !pragmaValue.MatchString(value) {
+			return nil, fmt.Errorf("invalid value for pragma %s: '%s'", name, value)
+		}
+		pragmas[name] = value
+	}
+	return pragmas, nil
+}
+
+// RegisterSQLiteDriver registers SQLiteDriver, which sets pragmas on each
+// connection as it's opened; PRAGMAs only last for a connection, so setting
+// them once after sql.Open would miss the rest of the pool. Queries that find
+// the database busy for longer than its busy_timeout are retried up to
+// busyRetries times.
+func RegisterSQLiteDriver(pragmas map[string]string, busyRetries int) {
+	sql.Register(SQLiteDriver, newSQLiteDriver(pragmas, busyRetries))
+}
+
+func newSQLiteDriver(pragmas map[string]string, busyRetries int) driver.Driver {
+	return &busyRetryDriver{
+		SQLiteDriver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for name, value := range pragmas {
+					if _, err := conn.Exec(fmt.Sprintf("PRAGMA %s = %s", name, value), nil); err != nil {
+						return fmt.Errorf("error setting pragma %s: %w", name, err)
+					}
+				}
+				return nil
+			},
+		},
+		retries: busyRetries,
+	}
+}
+
+// LogPragmas logs the effective value of each tunable PRAGMA, which SQLite
+// may have adjusted (or, for journal_mode, refused) from what was asked for.
+func LogPragmas(db *sql.DB) {
+	settings := make([]string, 0, len(tunablePragmas)+1)
+	for _, name := range slices.Concat(tunablePragmas, []string{"busy_timeout"}) {
+		var value string
+		if err := db.QueryRow("PRAGMA " + name).Scan(&value); err != nil {
+			log.Printf("WARNING: error reading pragma %s: %v", name, err)
+			continue
+		}
+		settings = append(settings, name+"="+value)
+	}
+	log.Printf("SQLite settings: %s", strings.Join(settings, ", "))
+}
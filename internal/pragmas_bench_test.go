@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// benchmarkPragmas run from SQLite's own defaults, with --pragma set to
+// nothing, to the --pragma defaults.
+var benchmarkPragmas = []struct {
+	name    string
+	pragmas map[string]string
+}{
+	{"SQLiteDefaults", nil},
+	{"CacheSize", map[string]string{"cache_size": "-65536"}},
+	{"MmapSize", map[string]string{"mmap_size": "268435456"}},
+	{"Defaults", map[string]string{"cache_size": "-65536", "mmap_size": "268435456", "temp_store": "memory"}},
+}
+
+func init() {
+	for _, tc := range benchmarkPragmas {
+		sql.Register("sqlite3_benchmark_"+tc.name, newSQLiteDriver(tc.pragmas, 0))
+	}
+}
+
+// benchmarkDBFile writes the generated benchmark dataset to a GeoPackage
+// file, as the PRAGMAs tune how a file is cached and read.
+func benchmarkDBFile(b *testing.B) string {
+	b.Helper()
+
+	db, err := testutil.NewDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	if err := testutil.Seed(db, testutil.Generate(benchmarkPOIs)); err != nil {
+		b.Fatal(err)
+	}
+
+	path := filepath.Join(b.TempDir(), "poi.gpkg")
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkSearchPragmas compares bbox searches of a GeoPackage file before
+// and after the cache_size and mmap_size PRAGMAs are applied.
+func BenchmarkSearchPragmas(b *testing.B) {
+	path := benchmarkDBFile(b)
+
+	previous := spatialIndex
+	b.Cleanup(func() { spatialIndex = previous })
+	spatialIndex = SpatialIndex{Reason: "not enabled"}
+	gin.SetMode(gin.ReleaseMode)
+
+	for _, pragmas := range benchmarkPragmas {
+		for _, tc := range benchmarkBBoxes {
+			b.Run(fmt.Sprintf("%s/%s", pragmas.name, tc.name), func(b *testing.B) {
+				db, err := sql.Open("sqlite3_benchmark_"+pragmas.name, "file:"+path+"?mode=ro")
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer func() { _ = db.Close() }()
+
+				r := gin.New()
+				r.GET("/search", Search(db, SearchConfig{}))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					w := httptest.NewRecorder()
+					r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?bbox="+tc.bbox, nil))
+					if w.Code != http.StatusOK {
+						b.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+					}
+				}
+			})
+		}
+	}
+}
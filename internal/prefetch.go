@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/kofalt/go-memoize"
+)
+
+// PrefetchImages warms the image cache in the background with the default
+// photo for each of the n most common categories that have a marker, at most
+// concurrency at a time, so the first visitors don't wait on Unsplash. It
+// does nothing without an access key, and stops early once every key's
+// rate-limit budget is spent.
+func PrefetchImages(cache *memoize.Memoizer, queries map[string]string, summary *Summary, n int, concurrency int) {
+	if n <= 0 {
+		return
+	}
+	keys := accessKeys()
+	if !keys.configured() {
+		log.Println("No Unsplash access key configured, skipping image prefetch")
+		return
+	}
+
+	icons := currentIcons()
+	categories := make([]string, 0, n)
+	for _, ranked := range rankCategories(summary.Categories) {
+		if len(categories) == n {
+			break
+		}
+		if _, ok := icons[ranked.Category]; ok {
+			categories = append(categories, ranked.Category)
+		}
+	}
+
+	go func() {
+		log.Printf("Prefetching images for %d categories", len(categories))
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, max(concurrency, 1))
+		for _, category := range categories {
+			if !keys.hasBudget() {
+				log.Println("Unsplash rate limit reached, stopping image prefetch")
+				break
+			}
+
+			slots <- struct{}{}
+			wg.Go(func() {
+				defer func() { <-slots }()
+				if _, err := searchPhotos(context.Background(), cache, queries, category, "landscape"); err != nil {
+					log.Printf("Error prefetching image for category %s: %v", category, err)
+				}
+			})
+		}
+		wg.Wait()
+		log.Println("Image prefetch complete")
+	}()
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrettyJSON indents JSON responses to requests with ?pretty=true, for
+// reading in a browser while debugging; responses are otherwise left compact.
+// It must be registered after the compression middleware, so that it
+// indents the body before it is compressed.
+func PrettyJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// c.Query would cache the query string, which SearchPost replaces.
+		if pretty, err := parseBool("pretty", c.Request.URL.Query().Get("pretty")); err != nil || !pretty {
+			c.Next()
+			return
+		}
+
+		w := &prettyWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		w.flushIndented()
+	}
+}
+
+// prettyWriter buffers JSON bodies so they can be indented once complete.
+// Anything else, such as marker images or event streams, passes straight
+// through.
+type prettyWriter struct {
+	gin.ResponseWriter
+	decided   bool
+	buffering bool
+	body      bytes.Buffer
+}
+
+// decide chooses whether to buffer from the Content-Type, which handlers set
+// before writing the body.
+func (w *prettyWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.buffering = err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func (w *prettyWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Flush is a no-op while buffering, as nothing is sent until the handler has
+// finished.
+func (w *prettyWriter) Flush() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *prettyWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *prettyWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// flushIndented sends the buffered body, indented.
+func (w *prettyWriter) flushIndented() {
+	if !w.buffering {
+		return
+	}
+
+	var indented bytes.Buffer
+	body := w.body.Bytes()
+	if err := json.Indent(&indented, body, "", "  "); err == nil {
+		body = indented.Bytes()
+	}
+	w.Header().Del("Content-Length")
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// sortRarity is the sort value ordering results by how rare their main
+// category is in the whole dataset, which no column can express.
+const sortRarity = "rarity"
+
+// sortByRarity orders POIs so that those whose main category occurs least
+// often in counts come first. Ties are broken by category name, keeping each
+// category's POIs together, and then by fid. POIs with no category, or one
+// missing from counts, sort last.
+func sortByRarity(pois []POI, counts map[string]int) {
+	rarity := func(poi POI) int {
+		if count, ok := counts[mainCategory(poi)]; ok {
+			return count
+		}
+		return math.MaxInt
+	}
+
+	slices.SortFunc(pois, func(a, b POI) int {
+		return cmp.Or(
+			cmp.Compare(rarity(a), rarity(b)),
+			cmp.Compare(mainCategory(a), mainCategory(b)),
+			cmp.Compare(a.Fid, b.Fid),
+		)
+	})
+}
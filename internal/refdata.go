@@ -1,39 +1,109 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RefDataResponse struct {
-	Count       int            `json:"count"`
-	LastUpdated string         `json:"last_updated"`
-	Categories  map[string]int `json:"categories"`
-	Attribution []string       `json:"attribution"`
+	Count int `json:"count"`
+	// LastUpdated is normalised to RFC 3339 in UTC, or "unknown".
+	LastUpdated string `json:"last_updated"`
+	// LastUpdatedRaw is last_change exactly as the GeoPackage records it.
+	LastUpdatedRaw string            `json:"last_updated_raw"`
+	Bounds         []float64         `json:"bounds,omitempty"`
+	Categories     map[string]int    `json:"categories"`
+	Labels         map[string]string `json:"labels"`
+	Attribution    []string          `json:"attribution"`
 }
 
-func RefData(db *sql.DB) gin.HandlerFunc {
+// Summary is the reference data derived from the database at startup.
+type Summary struct {
+	Count          int
+	LastUpdated    string
+	LastUpdatedRaw string
+	Bounds         []float64
+	Categories     map[string]int
+	Sources        map[string]int
+}
+
+// Summarize scans the database for reference data, degrading to empty or
+// "unknown" values where the GeoPackage tables are missing. The summary is
+// always returned, but an error says the POI table itself couldn't be read,
+// so that a caller holding an earlier summary can keep it instead.
+func Summarize(db *sql.DB) (*Summary, error) {
+	var failed []error
 	categories, count, err := precomputeCategories(db)
 	if err != nil {
-		log.Fatalf("error pre-computing categories: %v", err)
+		log.Printf("WARNING: error pre-computing categories, serving empty categories: %v", err)
+		categories, count = map[string]int{}, 0
+		failed = append(failed, err)
 	}
 
-	lastUpdated, err := retrieveLastUpdated(db)
+	lastUpdatedRaw := retrieveLastUpdated(db)
+	lastUpdated := normaliseTimestamp(lastUpdatedRaw)
+
+	bounds, err := retrieveBounds(db)
 	if err != nil {
-		log.Fatalf("error retrieving last updated timestamp: %v", err)
+		log.Printf("WARNING: error retrieving bounds: %v", err)
 	}
 
+	sources, err := retrieveSources(db)
+	if err != nil {
+		log.Printf("WARNING: error retrieving sources: %v", err)
+		sources = map[string]int{}
+		failed = append(failed, err)
+	}
+
+	return &Summary{
+		Count:          count,
+		LastUpdated:    lastUpdated,
+		LastUpdatedRaw: lastUpdatedRaw,
+		Bounds:         bounds,
+		Categories:     categories,
+		Sources:        sources,
+	}, errors.Join(failed...)
+}
+
+// RefData returns the dataset summary, served from the cache with an Age
+// header giving its age in seconds. With ?taxonomy=simple, categories are
+// totalled by simple category instead.
+func RefData(summaries *SummaryCache, labels Labels, sourceAttribution map[string]string, taxonomy Taxonomy) gin.HandlerFunc {
+	localizer := newLocalizer(labels)
+
 	return func(c *gin.Context) {
+		simple, err := parseTaxonomy(c.Query("taxonomy"), taxonomy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		summary, age := summaries.Get()
+
+		categories := summary.Categories
+		if simple {
+			categories = taxonomy.translateCounts(summary.Categories)
+		}
+
+		addVary(c, "Accept-Language")
+		c.Header("Age", strconv.Itoa(int(age.Seconds())))
 		c.JSON(http.StatusOK, RefDataResponse{
-			Count:       count,
-			LastUpdated: lastUpdated,
-			Categories:  categories,
-			Attribution: ATTRIBUTION,
+			Count:          summary.Count,
+			LastUpdated:    summary.LastUpdated,
+			LastUpdatedRaw: summary.LastUpdatedRaw,
+			Bounds:         summary.Bounds,
+			Categories:     categories,
+			Labels:         localizer.labelsFor(c.GetHeader("Accept-Language"), categories),
+			Attribution:    resolveAttribution(summary.Sources, sourceAttribution),
 		})
 	}
 }
@@ -43,24 +113,58 @@ func CategoryGroups(c *gin.Context) {
 	c.File("./data/category-groups.json")
 }
 
-func retrieveLastUpdated(db *sql.DB) (string, error) {
-	var timestamp string
-	err := db.QueryRow(`SELECT last_change FROM gpkg_contents`).Scan(&timestamp)
-	if err != nil {
-		return "", fmt.Errorf("error retrieving timestamp: %w", err)
+// lastChange returns the last_change gpkg_contents records for the POI table,
+// to key caches on, or "" if there is none or it can't be read, as on a
+// database without gpkg_contents: the caches then last only their TTL.
+func lastChange(ctx context.Context, db *sql.DB) string {
+	var lastChange sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT last_change FROM gpkg_contents WHERE table_name = ?`, mapping.Table).Scan(&lastChange)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("WARNING: error retrieving last change: %v", err)
 	}
+	return lastChange.String
+}
 
+func retrieveLastUpdated(db *sql.DB) string {
+	timestamp := lastChange(context.Background(), db)
 	if timestamp == "" {
-		return "unknown", nil
+		return "unknown"
 	}
 
 	log.Printf("Last updated timestamp in db: %s", timestamp)
-	return timestamp, nil
+	return timestamp
+}
+
+// timestampLayouts are the last_change formats written by common GeoPackage
+// tools. Those without a zone are taken to be UTC, as the spec requires.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// normaliseTimestamp converts a last_change value to RFC 3339 in UTC, or
+// "unknown" if it is in no recognised format.
+func normaliseTimestamp(raw string) string {
+	if raw == "unknown" {
+		return raw
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(raw)); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	log.Printf("WARNING: unrecognised last updated timestamp: %s", raw)
+	return "unknown"
 }
 
 func precomputeCategories(db *sql.DB) (map[string]int, int, error) {
 	log.Println("Pre-computing POI categories...")
-	rows, err := db.Query(`SELECT main_category, alternate_category FROM poi_uk`)
+	rows, err := db.Query("SELECT " + columnList("main_category", "alternate_category") + " FROM " + table())
 	if err != nil {
 		return nil, 0, fmt.Errorf("error querying database: %w", err)
 	}
@@ -92,8 +196,8 @@ func precomputeCategories(db *sql.DB) (map[string]int, int, error) {
 		}
 
 		if alternateCategory.Valid {
-			for cat := range strings.SplitSeq(alternateCategory.String, "|") {
-				incr(strings.TrimSpace(cat))
+			for cat := range alternateCategories(alternateCategory.String) {
+				incr(cat)
 			}
 		}
 
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+)
+
+func TestRetrieveLastUpdatedOfPOITable(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := db.Exec(`
+		INSERT INTO gpkg_contents (table_name, data_type, identifier, last_change)
+		VALUES ('other', 'features', 'other', '2000-01-01T00:00:00.000Z')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-seeding replaces the poi_uk row, leaving it after the other table's.
+	if err := testutil.Seed(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE gpkg_contents SET last_change = '2024-06-01T12:00:00.000Z' WHERE table_name = 'poi_uk'`); err != nil {
+		t.Fatal(err)
+	}
+
+	if timestamp, want := retrieveLastUpdated(db), "2024-06-01T12:00:00Z"; timestamp != want {
+		t.Errorf("retrieveLastUpdated = %s, want %s, the poi_uk table's", timestamp, want)
+	}
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"geods-poi-api/internal/geo"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+)
+
+// sortRelevance is the sort value ordering results by a score combining how
+// well their name matches ?q with how near they are to ?lat and ?long.
+const sortRelevance = "relevance"
+
+// RelevanceWeights tune the relevance score. A POI scores
+//
+//	(name × name match + distance × distance_scale / (distance_scale + metres)) × category
+//
+// where the name match is 1 for a name equal to q, 0.75 for one starting with
+// it, 0.5 for one with a word starting with it, 0.25 for one containing it
+// elsewhere and 0 otherwise (or when no q is given), compared ignoring case.
+// The distance term halves at distance_scale metres from the origin, and
+// category is the weight of the POI's main category, 1 unless listed.
+type RelevanceWeights struct {
+	Name          float64            `json:"name"`
+	Distance      float64            `json:"distance"`
+	DistanceScale float64            `json:"distance_scale"`
+	Categories    map[string]float64 `json:"categories"`
+}
+
+// DefaultRelevanceWeights weight the name match and distance equally, with
+// the distance term halving at 1km.
+var DefaultRelevanceWeights = RelevanceWeights{Name: 0.5, Distance: 0.5, DistanceScale: 1000}
+
+// LoadRelevanceWeights reads relevance weights from a JSON file, such as
+// {"name": 0.7, "distance": 0.3, "categories": {"hospital": 1.5}}. Fields the
+// file leaves out keep their defaults; without a file, the defaults are used.
+func LoadRelevanceWeights(path string) (RelevanceWeights, error) {
+	weights := DefaultRelevanceWeights
+	if path == "" {
+		return weights, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return weights, fmt.Errorf("error reading relevance weights: %w", err)
+	}
+	if err := json.Unmarshal(contents, &weights); err != nil {
+		return weights, fmt.Errorf("error parsing relevance weights: %w", err)
+	}
+
+	if weights.Name < 0 || weights.Distance < 0 || weights.DistanceScale <= 0 {
+		return weights, fmt.Errorf("relevance weights must not be negative, and distance_scale must be positive")
+	}
+	for category, weight := range weights.Categories {
+		if weight < 0 {
+			return weights, fmt.Errorf("invalid relevance weight %g for %s: must not be negative", weight, category)
+		}
+	}
+
+	log.Printf("Loaded relevance weights from %s", path)
+	return weights, nil
+}
+
+// nameMatch scores how well a name matches q, from 1 for an exact match down
+// to 0 for none.
+func nameMatch(name *string, q string) float64 {
+	if name == nil || q == "" {
+		return 0
+	}
+
+	n, q := strings.ToLower(strings.TrimSpace(*name)), strings.ToLower(q)
+	switch {
+	case n == q:
+		return 1
+	case strings.HasPrefix(n, q):
+		return 0.75
+	case strings.Contains(" "+n, " "+q):
+		return 0.5
+	case strings.Contains(n, q):
+		return 0.25
+	default:
+		return 0
+	}
+}
+
+// sortByRelevance scores POIs against q and the origin (lat, long), setting
+// their Score and Distance, and orders them highest score first. Ties go to
+// the nearer POI, then to the lower fid.
+func sortByRelevance(pois []POI, q string, lat, long float64, weights RelevanceWeights) {
+	for i := range pois {
+		poi := &pois[i]
+		distance := geo.Haversine(lat, long, float64(poi.Lat), float64(poi.Long))
+
+		category, ok := weights.Categories[mainCategory(*poi)]
+		if !ok {
+			category = 1
+		}
+		score := (weights.Name*nameMatch(poi.PrimaryName, q) +
+			weights.Distance*weights.DistanceScale/(weights.DistanceScale+distance)) * category
+
+		distance = math.Round(distance*10) / 10
+		score = math.Round(score*1e4) / 1e4
+		poi.Distance, poi.Score = &distance, &score
+	}
+
+	slices.SortFunc(pois, func(a, b POI) int {
+		return cmp.Or(
+			cmp.Compare(*b.Score, *a.Score),
+			cmp.Compare(*a.Distance, *b.Distance),
+			cmp.Compare(a.Fid, b.Fid),
+		)
+	})
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IconChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type MappingsDiff struct {
+	Added   map[string]string     `json:"added"`
+	Removed map[string]string     `json:"removed"`
+	Changed map[string]IconChange `json:"changed"`
+	// MissingIcons lists categories mapped onto icons that can't be found,
+	// which are accepted (as is the embedded file) but will fail to serve.
+	MissingIcons []string `json:"missing_icons"`
+}
+
+// LoadMarkerMappings replaces the embedded category to icon mappings with
+// those in the JSON file at path, if set.
+func LoadMarkerMappings(filename string, markers fs.FS) error {
+	if filename == "" {
+		return nil
+	}
+
+	diff, err := swapMarkerMappings(filename, markers)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Loaded marker mappings from %s (%d categories without an icon file)", filename, len(diff.MissingIcons))
+	return nil
+}
+
+// ReloadMarkers re-reads the marker mappings file and swaps it in, responding
+// with the categories added, removed and changed. An invalid file is rejected
+// and the current mappings stay in effect.
+func ReloadMarkers(filename string, markers fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if filename == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "no marker mappings file configured"})
+			return
+		}
+
+		diff, err := swapMarkerMappings(filename, markers)
+		if err != nil {
+			log.Printf("error reloading marker mappings: %v", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		log.Printf("Reloaded marker mappings from %s: %d added, %d removed, %d changed",
+			filename, len(diff.Added), len(diff.Removed), len(diff.Changed))
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+func swapMarkerMappings(filename string, markers fs.FS) (*MappingsDiff, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading marker mappings: %w", err)
+	}
+
+	var updated map[string]string
+	if err := json.Unmarshal(contents, &updated); err != nil {
+		return nil, fmt.Errorf("error parsing marker mappings: %w", err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("marker mappings file is empty")
+	}
+
+	missing := make([]string, 0)
+	for _, category := range slices.Sorted(maps.Keys(updated)) {
+		icon := updated[category]
+		if icon == "" {
+			continue
+		}
+		if !fs.ValidPath(icon) || path.Base(icon) != icon {
+			return nil, fmt.Errorf("invalid icon %q for category %s", icon, category)
+		}
+		if !exists(markers, icon) {
+			missing = append(missing, category)
+		}
+	}
+
+	iconsMu.Lock()
+	defer iconsMu.Unlock()
+
+	diff := diffMappings(icons, updated)
+	diff.MissingIcons = missing
+	icons = updated
+	return diff, nil
+}
+
+func diffMappings(before, after map[string]string) *MappingsDiff {
+	diff := &MappingsDiff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]IconChange),
+	}
+
+	for category, icon := range after {
+		previous, ok := before[category]
+		switch {
+		case !ok:
+			diff.Added[category] = icon
+		case previous != icon:
+			diff.Changed[category] = IconChange{From: previous, To: icon}
+		}
+	}
+
+	for category, icon := range before {
+		if _, ok := after[category]; !ok {
+			diff.Removed[category] = icon
+		}
+	}
+
+	return diff
+}
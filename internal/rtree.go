@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+)
+
+// rtreeStaleFraction is how far the R-tree's row count may drift from the
+// table's before the index is considered stale.
+const rtreeStaleFraction = 0.01
+
+// SpatialIndex records what was found of the GeoPackage R-tree index at
+// startup, and whether bbox queries use it.
+type SpatialIndex struct {
+	Table     string `json:"table"`
+	Present   bool   `json:"present"`
+	IndexRows int    `json:"index_rows"`
+	TableRows int    `json:"table_rows"`
+	Stale     bool   `json:"stale"`
+	InUse     bool   `json:"in_use"`
+	// Reason explains why the index is or isn't used.
+	Reason string `json:"reason"`
+}
+
+var spatialIndex = SpatialIndex{Reason: "not enabled"}
+
+// UseSpatialIndex switches bbox queries to the GeoPackage R-tree index after
+// comparing its row count with the POI table's, since a GeoPackage edited
+// without its triggers can leave the index out of date. If the index is
+// missing, stale, or indexes geometries that aren't WGS84, bbox queries keep
+// scanning the lat and long columns.
+func UseSpatialIndex(db *sql.DB) error {
+	index := SpatialIndex{Table: fmt.Sprintf("rtree_%s_%s", mapping.Table, mapping.Columns["geom"])}
+
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, index.Table).Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error looking up R-tree index: %w", err)
+	}
+	index.Present = err == nil
+
+	switch {
+	case !index.Present:
+		index.Reason = "no R-tree index"
+	default:
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + quoteIdent(index.Table)).Scan(&index.IndexRows); err != nil {
+			return fmt.Errorf("error counting R-tree index rows: %w", err)
+		}
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table()).Scan(&index.TableRows); err != nil {
+			return fmt.Errorf("error counting rows: %w", err)
+		}
+
+		drift := math.Abs(float64(index.IndexRows - index.TableRows))
+		index.Stale = drift > rtreeStaleFraction*float64(index.TableRows)
+		if index.Stale {
+			log.Printf("WARNING: R-tree index %s has %d rows but %s has %d; it is stale and won't be used",
+				index.Table, index.IndexRows, mapping.Table, index.TableRows)
+		}
+
+		var srid int32
+		err := db.QueryRow(`SELECT srs_id FROM gpkg_geometry_columns WHERE table_name = ? AND column_name = ?`,
+			mapping.Table, mapping.Columns["geom"]).Scan(&srid)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error retrieving geometry SRID: %w", err)
+		}
+
+		switch {
+		case index.Stale:
+			index.Reason = "index is stale"
+		case srid != sridWGS84:
+			index.Reason = fmt.Sprintf("geometries are not WGS84 (SRID %d)", srid)
+		default:
+			index.InUse = true
+			index.Reason = "index is up to date"
+		}
+	}
+
+	log.Printf("Spatial index: %s", index.Reason)
+	spatialIndex = index
+	return nil
+}
+
+// rtreeBBoxClause is bboxClause for when the R-tree index is in use,
+// selecting the POIs whose indexed envelope overlaps the bbox. It binds to
+// the same bboxArgs. The index stores envelopes as 32-bit floats rounded
+// outwards, so a POI within a few centimetres of the edge may be included.
+func rtreeBBoxClause() string {
+	return column("fid") + " IN (SELECT id FROM " + quoteIdent(spatialIndex.Table) +
+		" WHERE maxy >= ? AND miny <= ? AND maxx >= ? AND minx <= ?)"
+}
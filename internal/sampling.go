@@ -0,0 +1,38 @@
+package internal
+
+// samplePOIs thins pois to a spatially representative sample of at most limit,
+// keeping the first POI in each H3 cell at the finest resolution that leaves
+// few enough cells. Dense areas are thinned hardest, so coverage still shows.
+func samplePOIs(pois []POI, limit int) []POI {
+	return sampleByCell(pois, limit, func(poi POI) string { return poi.H3_15 })
+}
+
+// sampleByCell is samplePOIs for anything with an H3 cell, as given by cell.
+func sampleByCell[T any](items []T, limit int, cell func(T) string) []T {
+	for resolution := h3MaxResolution; resolution >= 0; resolution-- {
+		if sample := thinByCell(items, resolution, cell); len(sample) <= limit {
+			return sample
+		}
+	}
+
+	// Even the coarsest cells are too many, so fall back to truncating.
+	return thinByCell(items, 0, cell)[:limit]
+}
+
+// thinByCell keeps the first item in each H3 cell at resolution. Items with an
+// unparseable cell are always kept, as there's nothing to group them by.
+func thinByCell[T any](items []T, resolution int, cell func(T) string) []T {
+	seen := make(map[string]struct{})
+	sample := make([]T, 0)
+	for _, item := range items {
+		parent, err := h3Parent(cell(item), resolution)
+		if err == nil {
+			if _, exists := seen[parent]; exists {
+				continue
+			}
+			seen[parent] = struct{}{}
+		}
+		sample = append(sample, item)
+	}
+	return sample
+}
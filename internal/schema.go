@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poiColumns are the logical POI columns the API depends on; see ColumnMapping
+// for how they map onto the physical schema.
+var poiColumns = []string{
+	"fid", "geom", "id", "primary_name", "main_category", "alternate_category",
+	"address", "locality", "postcode", "region", "country", "source", "source_record_id",
+	"lat", "long", "h3_15", "easting", "northing", "lsoa21cd",
+}
+
+// optionalColumns are logical POI columns that some datasets lack; the API
+// adapts to whichever are present, as found by DetectOptionalColumns.
+var optionalColumns = []string{"confidence", "updated_at"}
+
+var presentColumns = map[string]bool{}
+
+// DetectOptionalColumns records which optional columns exist in the POI table.
+func DetectOptionalColumns(db *sql.DB) error {
+	columns, err := tableColumns(db, mapping.Table)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, name := range optionalColumns {
+		if _, ok := columns[mapping.Columns[name]]; ok {
+			present[name] = true
+			log.Printf("Optional column present: %s", name)
+		}
+	}
+
+	presentColumns = present
+	return nil
+}
+
+func hasColumn(name string) bool {
+	return presentColumns[name]
+}
+
+// selectedColumns lists the logical columns selected for each POI: all the
+// required columns followed by any optional columns that are present.
+func selectedColumns() []string {
+	columns := slices.Clone(poiColumns)
+	for _, name := range optionalColumns {
+		if hasColumn(name) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// tableColumns returns the column names and declared types of a table, or an
+// empty map if the table does not exist.
+func tableColumns(db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.Query("PRAGMA table_info(" + quoteIdent(table) + ")")
+	if err != nil {
+		return nil, fmt.Errorf("error introspecting table %s: %w", table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	columns := make(map[string]string)
+	var cid, notNull, pk int
+	var name, colType string
+	var defaultValue sql.NullString
+
+	for rows.Next() {
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("error scanning table info: %w", err)
+		}
+		columns[name] = colType
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return columns, nil
+}
+
+// filterParams lists, by logical column, the search parameters that filter
+// on it.
+var filterParams = map[string][]string{
+	"main_category":      {"categories"},
+	"alternate_category": {"categories"},
+	"primary_name":       {"named_only"},
+	"postcode":           {"postcode"},
+	"lat":                {"bbox"},
+	"long":               {"bbox"},
+	"h3_15":              {"h3_parent"},
+	"confidence":         {"min_confidence"},
+}
+
+type SchemaColumn struct {
+	// Name is the physical column name.
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Field is the logical POI field the column holds, if the API uses it.
+	Field string `json:"field,omitempty"`
+	// FilterParams are the search parameters that filter on the column.
+	FilterParams []string `json:"filter_params,omitempty"`
+	// SortKey is the search sort value that orders by the column.
+	SortKey string `json:"sort_key,omitempty"`
+}
+
+type SchemaResponse struct {
+	Table   string         `json:"table"`
+	Columns []SchemaColumn `json:"columns"`
+}
+
+// Schema describes the columns of the POI table, in table order, noting
+// which the search parameters filter and sort by. The schema can't change
+// under a running server, so it's introspected once, on first use.
+func Schema(db *sql.DB) gin.HandlerFunc {
+	var once sync.Once
+	var resp *SchemaResponse
+	var err error
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			resp, err = describeSchema(db)
+		})
+		if err != nil {
+			log.Printf("error describing schema: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func describeSchema(db *sql.DB) (*SchemaResponse, error) {
+	fields := make(map[string]string)
+	for field, physical := range mapping.Columns {
+		fields[physical] = field
+	}
+	sortKeys := make(map[string]string)
+	for key, field := range sortColumns {
+		sortKeys[field] = key
+	}
+
+	rows, err := db.Query("SELECT name, type FROM pragma_table_info(?) ORDER BY cid", mapping.Table)
+	if err != nil {
+		return nil, fmt.Errorf("error introspecting table %s: %w", mapping.Table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	columns := make([]SchemaColumn, 0)
+	for rows.Next() {
+		var col SchemaColumn
+		if err := rows.Scan(&col.Name, &col.Type); err != nil {
+			return nil, fmt.Errorf("error scanning table info: %w", err)
+		}
+		col.Field = fields[col.Name]
+		col.FilterParams = filterParams[col.Field]
+		col.SortKey = sortKeys[col.Field]
+		columns = append(columns, col)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return &SchemaResponse{Table: mapping.Table, Columns: columns}, nil
+}
+
+// VerifySchema checks that the database has the GeoPackage tables and POI
+// columns the API expects.
+func VerifySchema(db *sql.DB) error {
+	contents, err := tableColumns(db, "gpkg_contents")
+	if err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		return fmt.Errorf("table gpkg_contents not found: not a GeoPackage")
+	}
+
+	columns, err := tableColumns(db, mapping.Table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("table %s not found", mapping.Table)
+	}
+
+	var missing []string
+	for _, name := range poiColumns {
+		if _, ok := columns[mapping.Columns[name]]; !ok {
+			missing = append(missing, mapping.Columns[name])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("table %s is missing columns: %s", mapping.Table, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// CheckDatabase verifies the schema and reports the row count and extent of
+// the POI table, returning an error if the database is unusable.
+func CheckDatabase(db *sql.DB) error {
+	if err := VerifySchema(db); err != nil {
+		return err
+	}
+	log.Println("Schema OK")
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table()).Scan(&count); err != nil {
+		return fmt.Errorf("error counting rows: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("table %s is empty", mapping.Table)
+	}
+	log.Printf("Row count: %d", count)
+
+	bounds, err := retrieveBounds(db)
+	if err != nil {
+		return err
+	}
+	if bounds == nil {
+		log.Println("Extent: not recorded in gpkg_contents")
+	} else {
+		log.Printf("Extent: %f,%f,%f,%f", bounds[LEFT], bounds[BOTTOM], bounds[RIGHT], bounds[TOP])
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestSchemaQuotesTableNames(t *testing.T) {
+	db := newTestDB(t)
+
+	// Quotes and backslashes are quoted differently by Go and SQL.
+	const name = `poi "uk\ 2`
+	if _, err := db.Exec("CREATE TABLE " + quoteIdent(name) + " (fid INTEGER PRIMARY KEY, primary_name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"fid": "INTEGER", "primary_name": "TEXT"}
+
+	columns, err := tableColumns(db, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(columns, want) {
+		t.Errorf("tableColumns(%q) = %v, want %v", name, columns, want)
+	}
+
+	previous := mapping
+	t.Cleanup(func() { mapping = previous })
+	mapping.Table = name
+
+	resp, err := describeSchema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Columns) != 2 || resp.Columns[0].Name != "fid" || resp.Columns[1].Name != "primary_name" {
+		t.Errorf("describeSchema gave columns %+v, want fid and primary_name", resp.Columns)
+	}
+}
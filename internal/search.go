@@ -3,41 +3,116 @@ package internal
 import (
 	"database/sql"
 	"fmt"
+	"iter"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/twpayne/go-geom"
-	"github.com/twpayne/go-geom/encoding/wkb"
-	"github.com/twpayne/go-geom/encoding/wkt"
 )
 
 type SearchResponse struct {
-	Results     []POI    `json:"results"`
+	Results      []POI         `json:"results"`
+	Clamped      bool          `json:"clamped,omitempty"`
+	SnappedBBox  []float64     `json:"snapped_bbox,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	ResultBounds *ResultBounds `json:"result_bounds,omitempty"`
+	// Sampled is set when the results were thinned with ?sample=N.
+	Sampled bool `json:"sampled,omitempty"`
+	// Total is the number of POIs matched, when the results are only a
+	// subset of them, i.e. sampled or a page of ?limit=N&offset=M.
+	Total int `json:"total,omitempty"`
+	// SuppressedCategories lists the categories hidden at the requested
+	// ?zoom, reported only when running with --dev.
+	SuppressedCategories []string `json:"suppressed_categories,omitempty"`
+	// Truncated is set when results were cut short to keep the response
+	// within the configured maximum size.
+	Truncated   bool     `json:"truncated,omitempty"`
 	Attribution []string `json:"attribution"`
+	// Meta is only present when search attribution is enabled.
+	Meta *SearchMeta `json:"meta,omitempty"`
+}
+
+type SearchMeta struct {
+	// Attribution is the full dataset attribution, as listed by ref-data.
+	Attribution []string `json:"attribution"`
+}
+
+// ResultBounds is the extent of the POIs actually returned, which may be much
+// smaller than the bbox requested.
+type ResultBounds struct {
+	MinLat  Coordinate `json:"min_lat"`
+	MinLong Coordinate `json:"min_long"`
+	MaxLat  Coordinate `json:"max_lat"`
+	MaxLong Coordinate `json:"max_long"`
+}
+
+// extend grows the bounds to include poi, starting them if nil.
+func (b *ResultBounds) extend(poi POI) *ResultBounds {
+	if b == nil {
+		return &ResultBounds{MinLat: poi.Lat, MinLong: poi.Long, MaxLat: poi.Lat, MaxLong: poi.Long}
+	}
+	b.MinLat = min(b.MinLat, poi.Lat)
+	b.MinLong = min(b.MinLong, poi.Long)
+	b.MaxLat = max(b.MaxLat, poi.Lat)
+	b.MaxLong = max(b.MaxLong, poi.Long)
+	return b
 }
 
 type POI struct {
-	Fid            int      `json:"fid"`
-	Geom           string   `json:"geom"`
-	Id             string   `json:"id"`
-	PrimaryName    *string  `json:"primary_name,omitempty"`
-	Categories     []string `json:"categories,omitempty"`
-	Address        *string  `json:"address,omitempty"`
-	Locality       *string  `json:"locality,omitempty"`
-	Postcode       *string  `json:"postcode,omitempty"`
-	Region         *string  `json:"region,omitempty"`
-	Country        *string  `json:"country,omitempty"`
-	Source         string   `json:"source"`
-	SourceRecordId string   `json:"source_record_id"`
-	Lat            float64  `json:"lat"`
-	Long           float64  `json:"long"`
-	H3_15          string   `json:"h3_15"`
-	Easting        float64  `json:"easting"`
-	Northing       float64  `json:"northing"`
-	LSOA21CD       string   `json:"lsoa21cd"`
+	Fid int `json:"fid"`
+	// Geom is a WKT string, a [long, lat] array with ?geom_format=coords, or
+	// base64-encoded WKB with ?geom_format=wkb_b64.
+	Geom        any      `json:"geom"`
+	Id          string   `json:"id"`
+	PrimaryName *string  `json:"primary_name,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	// MainCategory and AlternateCategories split Categories apart, with
+	// ?category_detail=true. They are always the source categories.
+	MainCategory        *string    `json:"main_category,omitempty"`
+	AlternateCategories []string   `json:"alternate_categories,omitempty"`
+	Address             *string    `json:"address,omitempty"`
+	Locality            *string    `json:"locality,omitempty"`
+	Postcode            *string    `json:"postcode,omitempty"`
+	Region              *string    `json:"region,omitempty"`
+	Country             *string    `json:"country,omitempty"`
+	Source              string     `json:"source"`
+	SourceRecordId      string     `json:"source_record_id"`
+	Lat                 Coordinate `json:"lat"`
+	Long                Coordinate `json:"long"`
+	H3_15               string     `json:"h3_15"`
+	// H3 is the POI's cell at the resolution requested with ?h3_resolution.
+	H3       *string    `json:"h3,omitempty"`
+	Easting  Coordinate `json:"easting"`
+	Northing Coordinate `json:"northing"`
+	LSOA21CD string     `json:"lsoa21cd"`
+	// LSOA21NM is the name of the LSOA, with ?include_lsoa_name=true.
+	LSOA21NM   *string  `json:"lsoa21nm,omitempty"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	// UpdatedAt is when the POI last changed, for datasets that track it.
+	UpdatedAt *string `json:"updated_at,omitempty"`
+	// Distance is in metres from the point given to the nearest endpoint.
+	Distance *float64 `json:"distance,omitempty"`
+	// Score is the POI's relevance with ?sort=relevance.
+	Score *float64 `json:"score,omitempty"`
+	// Highlight is PrimaryName with the part matching ?q wrapped in the
+	// highlight delimiters, with ?highlight=true; see highlighter.
+	Highlight *string `json:"highlight,omitempty"`
+	// Sources lists the records merged into the POI with ?dedupe.
+	Sources []POISource `json:"sources,omitempty"`
+	// BBoxes lists the (zero-based) positions of the requested bboxes that
+	// contain the POI, when more than one bbox was requested.
+	BBoxes []int `json:"bboxes,omitempty"`
+	// SRID is the spatial reference the geometry was stored in, reported to
+	// aid debugging only when running with --dev.
+	SRID *int32 `json:"srid,omitempty"`
+
+	srid int32
+	// hasMainCategory records whether Categories starts with a main category.
+	hasMainCategory bool
 }
 
 const (
@@ -47,34 +122,364 @@ const (
 	TOP
 )
 
-func Search(db *sql.DB) gin.HandlerFunc {
+// SearchConfig holds the deployment-level options for Search.
+type SearchConfig struct {
+	// Dev enables diagnostics, such as ?explain=true, which would otherwise
+	// leak schema details in production.
+	Dev bool
+	// Taxonomy backs ?taxonomy=simple; nil if none was loaded.
+	Taxonomy Taxonomy
+	// QueryTimeout caps how long a search query may run; zero is unlimited.
+	QueryTimeout time.Duration
+	// DefaultBBox is searched when no bbox is given, a page at a time. If
+	// nil, a bbox is required.
+	DefaultBBox []float64
+	// FlushRows streams plain JSON results, flushing every FlushRows POIs,
+	// when they need no processing once read. Zero buffers every response.
+	FlushRows int
+	// Attribution, if set, gives the dataset attribution to include in each
+	// response's meta, for clients that never call ref-data.
+	Attribution func() []string
+	// LSOANames maps LSOA codes to names; nil if no lookup is available.
+	LSOANames map[string]string
+	// MinZooms hides categories from searches at a lower ?zoom; nil shows
+	// every category at every zoom.
+	MinZooms MinZooms
+	// Relevance weights the score ?sort=relevance orders results by.
+	Relevance RelevanceWeights
+	// CategoryCounts, if set, returns the dataset-wide count of each
+	// category, backing ?sort=rarity.
+	CategoryCounts func() map[string]int
+	// MaxResponseBytes caps the size of the results in a search response,
+	// as JSON, truncating them once reached; zero is unlimited.
+	MaxResponseBytes int
+	// LogFiltered logs how many rows each search fetched and how many its
+	// in-process filters dropped.
+	LogFiltered bool
+}
+
+// defaultPageSize limits searches of the default bbox that don't set a limit,
+// which would otherwise return the whole dataset.
+const defaultPageSize = 100
+
+// ParseDefaultBBox parses the configured default bbox: empty for none,
+// "extent" for the dataset bounds, or "left,bottom,right,top".
+func ParseDefaultBBox(value string, summary *Summary) ([]float64, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "extent":
+		if summary.Bounds == nil {
+			return nil, fmt.Errorf("the dataset extent is unknown")
+		}
+		return summary.Bounds, nil
+	default:
+		return parseBBox(value)
+	}
+}
+
+func Search(db *sql.DB, cfg SearchConfig) gin.HandlerFunc {
+	bounds, err := retrieveBounds(db)
+	if err != nil {
+		log.Printf("error retrieving bounds, bbox clamping disabled: %v", err)
+	}
+	counts := newCountCache(db)
+
 	return func(c *gin.Context) {
-		bbox, err := parseBBox(c.Query("bbox"))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		addVary(c, "Accept-Encoding", "Accept")
+
+		var errs paramErrors
+
+		// A cell given as h3_parent selects its descendants in place of a bbox.
+		h3Cell := strings.TrimSpace(c.Query("h3_parent"))
+		bboxValues := c.QueryArray("bbox")
+		defaulted := len(bboxValues) == 0 && cfg.DefaultBBox != nil && h3Cell == ""
+		var bboxes [][]float64
+		var firstChild, lastChild string
+		switch {
+		case h3Cell != "" && len(bboxValues) > 0:
+			errs.add("h3_parent", fmt.Errorf("h3_parent cannot be combined with bbox"))
+		case h3Cell != "":
+			var err error
+			firstChild, lastChild, err = h3ChildrenRange(h3Cell)
+			errs.add("h3_parent", err)
+		case defaulted:
+			bboxes = [][]float64{cfg.DefaultBBox}
+		default:
+			var err error
+			bboxes, err = parseBBoxes(bboxValues)
+			errs.add("bbox", err)
+		}
+		snap, err := parseBool("snap", c.Query("snap"))
+		errs.add("snap", err)
+		categoryExact, err := parseBool("category_exact", c.Query("category_exact"))
+		errs.add("category_exact", err)
+		categories, err := parseCategoryFilter(c.Query("categories"), categoryExact)
+		errs.add("categories", err)
+		// With the simple taxonomy, both the categories filter and the
+		// categories returned are in terms of the simple categories.
+		simple, err := parseTaxonomy(c.Query("taxonomy"), cfg.Taxonomy)
+		errs.add("taxonomy", err)
+		namedOnly, err := parseBool("named_only", c.Query("named_only"))
+		errs.add("named_only", err)
+		postcode := strings.TrimSpace(c.Query("postcode"))
+		perCategoryLimit, err := parseLimit("per_category_limit", c.Query("per_category_limit"))
+		errs.add("per_category_limit", err)
+		sample, err := parseLimit("sample", c.Query("sample"))
+		errs.add("sample", err)
+		limit, err := parseLimit("limit", c.Query("limit"))
+		errs.add("limit", err)
+		offset, err := parseOffset(c.Query("offset"))
+		errs.add("offset", err)
+		minConfidence, err := parseConfidence(c.Query("min_confidence"))
+		errs.add("min_confidence", err)
+		q := strings.TrimSpace(c.Query("q"))
+		highlight, err := parseHighlighter(c)
+		errs.add("highlight", err)
+		// Rarity and relevance are sorted in memory once every result is
+		// fetched, so the query is left in fid order.
+		sortValue := strings.TrimSpace(c.Query("sort"))
+		rarity := sortValue == sortRarity
+		relevance := sortValue == sortRelevance
+		var originLat, originLong float64
+		switch {
+		case rarity:
+			sortValue = ""
+			if cfg.CategoryCounts == nil {
+				errs.add("sort", fmt.Errorf("sort=%s is unavailable", sortRarity))
+			}
+		case relevance:
+			sortValue = ""
+			originLat, err = parseCoordinate("lat", c.Query("lat"), 90)
+			errs.add("lat", err)
+			originLong, err = parseCoordinate("long", c.Query("long"), 180)
+			errs.add("long", err)
+		}
+		sortInMemory := rarity || relevance
+		order, err := orderClause(sortValue)
+		errs.add("sort", err)
+		includeBounds, err := parseBool("include_bounds", c.Query("include_bounds"))
+		errs.add("include_bounds", err)
+		skipErrors, err := parseBool("skip_errors", c.Query("skip_errors"))
+		errs.add("skip_errors", err)
+		format, err := parseGeomFormat(c.Query("geom_format"))
+		errs.add("geom_format", err)
+		grouped, err := parseGroupBy(c.Query("group_by"))
+		errs.add("group_by", err)
+		explain, err := parseBool("explain", c.Query("explain"))
+		errs.add("explain", err)
+		categoryDetail, err := parseBool("category_detail", c.Query("category_detail"))
+		errs.add("category_detail", err)
+		includeLSOAName, err := parseBool("include_lsoa_name", c.Query("include_lsoa_name"))
+		errs.add("include_lsoa_name", err)
+		dedupe, err := parseDedupe(c.Query("dedupe"))
+		errs.add("dedupe", err)
+		pointsOnly, err := parseFormat(c.Query("format"))
+		errs.add("format", err)
+		shapeMap, err := parseShape(c.Query("shape"))
+		errs.add("shape", err)
+		zoom, err := parseZoom(c.Query("zoom"))
+		errs.add("zoom", err)
+		h3Resolution := -1
+		if value := c.Query("h3_resolution"); value != "" {
+			h3Resolution, err = parseResolution(value)
+			errs.add("h3_resolution", err)
+		}
+
+		if len(errs) > 0 {
+			errs.respond(c)
 			return
 		}
 
-		categories, err := parseCategories(c.Query("categories"))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if defaulted && limit == 0 {
+			limit = defaultPageSize
+		}
+
+		// Categories too small to make out at the map's zoom are left out.
+		var suppressed []string
+		if zoom >= 0 {
+			suppressed = cfg.MinZooms.suppressedAt(zoom)
+		}
+
+		// withMeta adds what every search response carries to resp, however it
+		// ends up being written.
+		withMeta := func(resp SearchResponse) SearchResponse {
+			resp.Attribution = ATTRIBUTION
+			if cfg.Attribution != nil {
+				resp.Meta = &SearchMeta{Attribution: cfg.Attribution()}
+			}
+			if cfg.Dev {
+				resp.SuppressedCategories = suppressed
+			}
+			return resp
+		}
+		respond := func(resp SearchResponse) {
+			resp = withMeta(resp)
+			if shapeMap {
+				respondSearchMap(c, resp)
+				return
+			}
+			respondSearch(c, resp)
+		}
+
+		var snapped []float64
+		clamped := false
+		var boxes [][]float64
+		var where string
+		var args []any
+		if h3Cell != "" {
+			where = column("h3_15") + " BETWEEN ? AND ? AND " + hasCoordinates()
+			args = []any{firstChild, lastChild}
+		} else {
+			// Snapping over-fetches a little so that slightly different views
+			// share a bbox, which clients can then use as their cache key. Boxes
+			// outside the dataset are dropped, leaving a nil entry so results can
+			// still be tagged with their position in the request.
+			boxes = make([][]float64, len(bboxes))
+			for i, bbox := range bboxes {
+				if snap {
+					bbox = snapBBox(bbox)
+					if len(bboxes) == 1 {
+						snapped = bbox
+					}
+				}
+
+				bbox, wasClamped, overlaps := clampBBox(bbox, bounds)
+				clamped = clamped || wasClamped
+				if overlaps {
+					boxes[i] = bbox
+				}
+			}
+
+			if !slices.ContainsFunc(boxes, func(bbox []float64) bool { return bbox != nil }) {
+				if pointsOnly {
+					respondPoints(c, [][2]Coordinate{})
+					return
+				}
+				respond(SearchResponse{Results: []POI{}, Clamped: true, SnappedBBox: snapped})
+				return
+			}
+
+			// In bbox: [LEFT, BOTTOM, RIGHT, TOP]
+			// So: bbox[LEFT]=min long, bbox[BOTTOM]=min lat, bbox[RIGHT]=max long, bbox[TOP]=max lat
+			where, args = bboxesClause(boxes)
+		}
+
+		if namedOnly {
+			where += " AND " + column("primary_name") + " IS NOT NULL AND " + column("primary_name") + " != ''"
+		}
+
+		if q != "" {
+			clause, arg := likeContains(column("primary_name"), q)
+			where += " AND " + clause
+			args = append(args, arg)
+		}
+
+		if postcode != "" {
+			clause, arg := likePrefix(column("postcode"), postcode)
+			where += " AND " + clause
+			args = append(args, arg)
+		}
+
+		// Datasets without a confidence column can't be filtered by it, so
+		// the parameter is ignored rather than rejected.
+		if minConfidence != nil && hasColumn("confidence") {
+			where += " AND " + column("confidence") + " >= ?"
+			args = append(args, *minConfidence)
+		}
+
+		if len(suppressed) > 0 {
+			clause, clauseArgs := suppressedClause(suppressed)
+			where += " AND " + clause
+			args = append(args, clauseArgs...)
+		}
+
+		ctx, cancel := withQueryTimeout(c.Request.Context(), cfg.QueryTimeout)
+		defer cancel()
+
+		// Heatmaps need only coordinates, so skip everything per-POI and
+		// respond with a bare [[long, lat], ...] array.
+		if pointsOnly {
+			var keep func([]string) bool
+			if len(categories) > 0 {
+				keep = func(items []string) bool {
+					if simple {
+						items = cfg.Taxonomy.translate(items)
+					}
+					if categoryExact {
+						return hasExactCategoryMatch(items, categories)
+					}
+					return hasCategoryMatch(items, categories)
+				}
+			}
+			limit := maxPoints
+			if sample > 0 {
+				limit = min(sample, maxPoints)
+			}
+
+			points, err := searchPoints(ctx, db, where, args, keep, limit)
+			if err != nil {
+				if queryTimedOut(ctx, err) {
+					log.Printf("search query timed out after %s", cfg.QueryTimeout)
+					respondQueryTimeout(c)
+					return
+				}
+				log.Printf("error searching points: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+
+			respondPoints(c, points)
+			return
+		}
+
+		// Pages can be cut in SQL, with a cached COUNT(*) for the total,
+		// unless rows are filtered after the query, in which case the whole
+		// result set is fetched and paged once filtered.
+		query := "SELECT " + columnList(selectedColumns()...) + " FROM " + table() + " WHERE " + where + order
+		if grouped {
+			query = "SELECT " + groupedColumnList() + " FROM " + table() + " WHERE " + where + " GROUP BY " + column("id") + order
+		}
+		queryArgs := args
+		filteredAfter := len(categories) > 0 || perCategoryLimit > 0 || sample > 0 || grouped || dedupe > 0 || sortInMemory
+		total := 0
+		if limit > 0 && !filteredAfter {
+			query += " LIMIT ? OFFSET ?"
+			queryArgs = append(slices.Clip(args), limit, offset)
+
+			if !(explain && cfg.Dev) {
+				total, err = counts.count(ctx, where, args)
+				if err != nil {
+					if queryTimedOut(ctx, err) {
+						log.Printf("search count timed out after %s", cfg.QueryTimeout)
+						respondQueryTimeout(c)
+						return
+					}
+					log.Printf("error counting results: %v", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+					return
+				}
+			}
+		}
+
+		if explain && cfg.Dev {
+			resp, err := explainQuery(db, query, queryArgs)
+			if err != nil {
+				log.Printf("error explaining query: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			c.JSON(http.StatusOK, resp)
 			return
 		}
 
-		// In bbox: [LEFT, BOTTOM, RIGHT, TOP]
-		// So: bbox[LEFT]=min long, bbox[BOTTOM]=min lat, bbox[RIGHT]=max long, bbox[TOP]=max lat
-		rows, err := db.Query(`
-				SELECT
-				fid, geom, id, primary_name, main_category, alternate_category,
-				address, locality, postcode, region, country, source, source_record_id,
-				lat, long, h3_15, easting, northing, lsoa21cd
-				FROM poi_uk
-				WHERE lat BETWEEN ? AND ?
-				AND long BETWEEN ? AND ?
-			`,
-			bbox[BOTTOM], bbox[TOP], bbox[LEFT], bbox[RIGHT],
-		)
+		rows, err := db.QueryContext(ctx, query, queryArgs...)
 		if err != nil {
+			if queryTimedOut(ctx, err) {
+				log.Printf("search query timed out after %s", cfg.QueryTimeout)
+				respondQueryTimeout(c)
+				return
+			}
 			log.Printf("error querying database: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
 			return
@@ -86,53 +491,343 @@ func Search(db *sql.DB) gin.HandlerFunc {
 		}()
 
 		var results []POI
-		var poi POI
-		var mainCategory sql.NullString
-		var alternateCategory sql.NullString
-		var geomBytes []byte
+		var resultBounds *ResultBounds
+		perCategory := make(map[string]int)
+		skipped := 0
+		stats := newFilterStats()
+		defer stats.record(cfg.LogFiltered)
 
-		for rows.Next() {
-			if err := rows.Scan(&poi.Fid, &geomBytes, &poi.Id, &poi.PrimaryName, &mainCategory, &alternateCategory,
-				&poi.Address, &poi.Locality, &poi.Postcode, &poi.Region, &poi.Country, &poi.Source, &poi.SourceRecordId,
-				&poi.Lat, &poi.Long, &poi.H3_15, &poi.Easting, &poi.Northing, &poi.LSOA21CD); err != nil {
+		// Streamed results are written as they are read, so once the
+		// first is sent, errors can only be logged, truncating the response.
+		var stream *resultStream
+		if cfg.FlushRows > 0 && sample == 0 && dedupe == 0 && !sortInMemory && !shapeMap && !(limit > 0 && filteredAfter) && !wantsMsgpack(c) && !wantsJSONAPI(c) {
+			stream = newResultStream(c, cfg.FlushRows, cfg.MaxResponseBytes)
+		}
 
+		for rows.Next() {
+			poi, err := scanPOI(rows, scanOptions{format: format, grouped: grouped})
+			if err != nil && skipErrors {
+				log.Printf("skipping unreadable row: %v", err)
+				skipped++
+				continue
+			}
+			if err != nil {
 				log.Printf("error scanning row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				if stream == nil || !stream.started() {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				}
 				return
 			}
 
-			poi.Geom, err = wkbPointToWKT(geomBytes)
-			if err != nil {
-				log.Printf("error converting WKB to WKT: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
-				return
+			stats.fetched++
+
+			if categoryDetail {
+				poi.splitCategoryDetail()
+			}
+
+			if simple {
+				poi.Categories = cfg.Taxonomy.translate(poi.Categories)
 			}
 
-			poi.Categories = make([]string, 0)
-			if mainCategory.Valid {
-				poi.Categories = append(poi.Categories, mainCategory.String)
+			if len(bboxes) > 1 {
+				poi.BBoxes = containingBBoxes(boxes, poi)
 			}
-			if alternateCategory.Valid {
-				for cat := range strings.SplitSeq(alternateCategory.String, "|") {
-					poi.Categories = append(poi.Categories, strings.TrimSpace(cat))
+
+			if h3Resolution >= 0 {
+				if cell, err := h3Parent(poi.H3_15, h3Resolution); err == nil {
+					poi.H3 = &cell
+				} else {
+					log.Printf("unable to compute H3 cell for fid %d: %v", poi.Fid, err)
 				}
 			}
 
-			if len(categories) == 0 || hasCategoryMatch(poi.Categories, categories) {
-				results = append(results, poi)
+			// Without a lookup, or for an unknown code, the name is omitted.
+			if includeLSOAName {
+				if name, ok := cfg.LSOANames[poi.LSOA21CD]; ok {
+					poi.LSOA21NM = &name
+				}
+			}
+
+			if highlight != nil && q != "" && poi.PrimaryName != nil {
+				poi.Highlight = highlight.match(*poi.PrimaryName, q)
+			}
+
+			if cfg.Dev {
+				srid := poi.srid
+				poi.SRID = &srid
 			}
+
+			matches := hasCategoryMatch
+			if categoryExact {
+				matches = hasExactCategoryMatch
+			}
+			if len(categories) > 0 && !matches(poi.Categories, categories) {
+				stats.drop("category", 1)
+				continue
+			}
+
+			// Cap each main category so that a few common ones can't crowd
+			// out the rarer categories in the same area.
+			if perCategoryLimit > 0 {
+				main := mainCategory(poi)
+				if perCategory[main] >= perCategoryLimit {
+					stats.drop("per_category_limit", 1)
+					continue
+				}
+				perCategory[main]++
+			}
+
+			if stream != nil {
+				if err := stream.write(poi); err != nil {
+					log.Printf("error streaming results: %v", err)
+					return
+				}
+				if stream.truncated {
+					break
+				}
+				if includeBounds {
+					resultBounds = resultBounds.extend(poi)
+				}
+				continue
+			}
+
+			results = append(results, poi)
 		}
 		if err = rows.Err(); err != nil {
-			log.Printf("error during rows iteration: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			switch {
+			case stream != nil && stream.started():
+				log.Printf("error during rows iteration, truncating streamed results: %v", err)
+			case queryTimedOut(ctx, err):
+				log.Printf("search query timed out after %s", cfg.QueryTimeout)
+				respondQueryTimeout(c)
+			default:
+				log.Printf("error during rows iteration: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			}
+			return
+		}
+
+		if dedupe > 0 {
+			before := len(results)
+			results = dedupePOIs(results, dedupe)
+			stats.drop("dedupe", before-len(results))
+		}
+
+		// Dense areas can be thinned to a sample rather than overplotting the
+		// map; total then reports how many POIs matched before sampling.
+		sampled := sample > 0 && len(results) > sample
+		if sampled {
+			total = len(results)
+			results = samplePOIs(results, sample)
+		}
+
+		if rarity {
+			counts := cfg.CategoryCounts()
+			if simple {
+				counts = cfg.Taxonomy.translateCounts(counts)
+			}
+			sortByRarity(results, counts)
+		}
+
+		if relevance {
+			sortByRelevance(results, q, originLat, originLong, cfg.Relevance)
+		}
+
+		if limit > 0 && filteredAfter {
+			total = len(results)
+			results = paginate(results, offset, limit)
+		}
+
+		truncated := false
+		if cfg.MaxResponseBytes > 0 && stream == nil {
+			results, truncated = truncateResults(results, cfg.MaxResponseBytes)
+			if truncated {
+				c.Header(truncatedHeader, "true")
+			}
+		}
+
+		if includeBounds {
+			for _, poi := range results {
+				resultBounds = resultBounds.extend(poi)
+			}
+		}
+
+		var warnings []string
+		if skipped > 0 {
+			warnings = append(warnings, fmt.Sprintf("skipped %d unreadable row(s)", skipped))
+		}
+
+		resp := SearchResponse{
+			Results:      results,
+			Clamped:      clamped,
+			SnappedBBox:  snapped,
+			Warnings:     warnings,
+			ResultBounds: resultBounds,
+			Sampled:      sampled,
+			Total:        total,
+			Truncated:    truncated,
+		}
+		if stream != nil {
+			if err := stream.finish(withMeta(resp)); err != nil {
+				log.Printf("error streaming results: %v", err)
+			}
 			return
 		}
+		respond(resp)
+	}
+}
+
+// respondSearch writes a search response in the plain JSON shape, or as
+// MessagePack or a JSON:API document when the client negotiated for one.
+func respondSearch(c *gin.Context, resp SearchResponse) {
+	switch {
+	case wantsMsgpack(c):
+		renderMsgpack(c, http.StatusOK, resp)
+	case wantsJSONAPI(c):
+		c.Header("Content-Type", jsonAPIMediaType)
+		c.JSON(http.StatusOK, toJSONAPI(resp))
+	default:
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// scanPOI scans a row selected with columnList(selectedColumns()...), or
+// groupedColumnList, into a POI, decoding its geometry and flattening its
+// categories.
+func scanPOI(rows *sql.Rows, opts scanOptions) (POI, error) {
+	var poi POI
+	var mainCategory sql.NullString
+	var alternateCategory sql.NullString
+	var geomBytes []byte
+	var confidence sql.NullFloat64
+	var lat, long sql.NullFloat64
+
+	dest := []any{&poi.Fid, &geomBytes, &poi.Id, &poi.PrimaryName, &mainCategory, &alternateCategory,
+		&poi.Address, &poi.Locality, &poi.Postcode, &poi.Region, &poi.Country, &poi.Source, &poi.SourceRecordId,
+		&lat, &long, &poi.H3_15, &poi.Easting, &poi.Northing, &poi.LSOA21CD}
+	if hasColumn("confidence") {
+		dest = append(dest, &confidence)
+	}
+	if hasColumn("updated_at") {
+		dest = append(dest, &poi.UpdatedAt)
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return poi, err
+	}
+
+	// Queries exclude rows without coordinates (see hasCoordinates); one
+	// reaching here is reported rather than placed at (0, 0).
+	if !lat.Valid || !long.Valid {
+		return poi, fmt.Errorf("POI %d has no coordinates", poi.Fid)
+	}
+	poi.Lat, poi.Long = Coordinate(lat.Float64), Coordinate(long.Float64)
+
+	if confidence.Valid {
+		poi.Confidence = &confidence.Float64
+	}
+
+	var err error
+	if opts.grouped {
+		poi.Geom, poi.srid, err = decodeGroupedGeometry(geomBytes, opts.format)
+	} else {
+		poi.Geom, poi.srid, err = decodeGeometry(geomBytes, opts.format)
+	}
+	if err != nil {
+		return poi, fmt.Errorf("error decoding geometry: %w", err)
+	}
+
+	poi.Categories = splitCategories(mainCategory, alternateCategory)
+	poi.hasMainCategory = mainCategory.Valid
+	return poi, nil
+}
 
-		c.JSON(http.StatusOK, SearchResponse{
-			Results:     results,
-			Attribution: ATTRIBUTION,
-		})
+// splitCategoryDetail fills in MainCategory and AlternateCategories from the
+// flattened Categories.
+func (poi *POI) splitCategoryDetail() {
+	alternates := poi.Categories
+	if poi.hasMainCategory {
+		main := poi.Categories[0]
+		poi.MainCategory = &main
+		alternates = poi.Categories[1:]
 	}
+	poi.AlternateCategories = slices.Clone(alternates)
+}
+
+// hasCoordinates is the WHERE predicate excluding rows with a NULL lat or
+// long, for queries not already bounded by a bbox.
+func hasCoordinates() string {
+	return column("lat") + " IS NOT NULL AND " + column("long") + " IS NOT NULL"
+}
+
+// bboxClause is the WHERE predicate selecting POIs within a bbox; bind it
+// with bboxArgs.
+func bboxClause() string {
+	if spatialIndex.InUse {
+		return rtreeBBoxClause()
+	}
+	return column("lat") + " BETWEEN ? AND ? AND " + column("long") + " BETWEEN ? AND ?"
+}
+
+// bboxArgs returns the bind arguments for bboxClause. In bbox: [LEFT, BOTTOM,
+// RIGHT, TOP], so bbox[LEFT]=min long, bbox[BOTTOM]=min lat, bbox[RIGHT]=max
+// long, bbox[TOP]=max lat.
+func bboxArgs(bbox []float64) []any {
+	return []any{bbox[BOTTOM], bbox[TOP], bbox[LEFT], bbox[RIGHT]}
+}
+
+// maxBBoxes caps how many bboxes a single search may request.
+const maxBBoxes = 10
+
+// parseBBoxes parses one or more bbox parameters.
+func parseBBoxes(values []string) ([][]float64, error) {
+	if len(values) == 0 {
+		values = []string{""}
+	}
+	if len(values) > maxBBoxes {
+		return nil, fmt.Errorf("at most %d bbox values may be given", maxBBoxes)
+	}
+
+	bboxes := make([][]float64, 0, len(values))
+	for _, value := range values {
+		bbox, err := parseBBox(value)
+		if err != nil {
+			return nil, err
+		}
+		bboxes = append(bboxes, bbox)
+	}
+
+	return bboxes, nil
+}
+
+// bboxesClause ORs together bboxClause for each non-nil bbox, so a POI in
+// several overlapping boxes is still only returned once.
+func bboxesClause(bboxes [][]float64) (string, []any) {
+	var clauses []string
+	var args []any
+	for _, bbox := range bboxes {
+		if bbox != nil {
+			clauses = append(clauses, bboxClause())
+			args = append(args, bboxArgs(bbox)...)
+		}
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0], args
+	}
+	return "((" + strings.Join(clauses, ") OR (") + "))", args
+}
+
+// containingBBoxes returns the positions of the non-nil bboxes containing poi.
+func containingBBoxes(bboxes [][]float64, poi POI) []int {
+	var positions []int
+	for i, bbox := range bboxes {
+		lat, long := float64(poi.Lat), float64(poi.Long)
+		if bbox != nil && lat >= bbox[BOTTOM] && lat <= bbox[TOP] && long >= bbox[LEFT] && long <= bbox[RIGHT] {
+			positions = append(positions, i)
+		}
+	}
+	return positions
 }
 
 func parseBBox(bboxStr string) ([]float64, error) {
@@ -153,49 +848,119 @@ func parseBBox(bboxStr string) ([]float64, error) {
 	return bbox, nil
 }
 
-func wkbPointToWKT(geomBytes []byte) (string, error) {
-	if len(geomBytes) < 8 {
-		return "", fmt.Errorf("input byte slice is too short to contain a GeoPackage header and WKB data")
+// parseConfidence parses a minimum confidence score, which must lie in the
+// range 0 (least confident) to 1 (most confident).
+func parseConfidence(str string) (*float64, error) {
+	if str == "" {
+		return nil, nil
 	}
 
-	wkbData := geomBytes[8:]
-
-	g, err := wkb.Unmarshal(wkbData)
-	if err != nil {
-		return "", fmt.Errorf("error unmarshaling WKB: %w", err)
+	confidence, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || confidence < 0 || confidence > 1 {
+		return nil, fmt.Errorf("invalid min_confidence value '%s': must be a number between 0 and 1", str)
 	}
 
-	point, ok := g.(*geom.Point)
-	if !ok {
-		return "", fmt.Errorf("decoded geometry is not a Point, but a %T", g)
+	return &confidence, nil
+}
+
+func parseBool(name string, value string) (bool, error) {
+	if value == "" {
+		return false, nil
 	}
 
-	wktString, err := wkt.Marshal(point)
+	b, err := strconv.ParseBool(value)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling to WKT: %w", err)
+		return false, fmt.Errorf("invalid %s value '%s': not a valid boolean", name, value)
 	}
 
-	return wktString, nil
+	return b, nil
+}
+
+// maxCategories caps the number of categories a single request may filter
+// by, bounding the size of the category set held for each query.
+var maxCategories = 100
+
+// SetMaxCategories sets the most categories accepted in one request.
+func SetMaxCategories(limit int) {
+	maxCategories = limit
 }
 
 func parseCategories(categoriesStr string) (map[string]struct{}, error) {
+	return parseCategoryFilter(categoriesStr, false)
+}
+
+// parseCategoryFilter parses a comma-separated list of categories, trimmed
+// and lower-cased unless exact, in which case they must match verbatim.
+func parseCategoryFilter(categoriesStr string, exact bool) (map[string]struct{}, error) {
 	if categoriesStr == "" {
 		return nil, nil // No categories specified, return nil
 	}
 
 	categories := make(map[string]struct{})
 	for cat := range strings.SplitSeq(categoriesStr, ",") {
-		cat = strings.TrimSpace(cat)
-		if cat == "" {
+		if !exact {
+			cat = strings.ToLower(strings.TrimSpace(cat))
+		}
+		if strings.TrimSpace(cat) == "" {
 			return nil, fmt.Errorf("category cannot be an empty string")
 		}
-		categories[strings.ToLower(cat)] = struct{}{}
+		categories[cat] = struct{}{}
+		if len(categories) > maxCategories {
+			return nil, fmt.Errorf("at most %d categories may be given", maxCategories)
+		}
 	}
 
 	return categories, nil
 }
 
+// categoryDelimiter separates the categories in alternate_category.
+var categoryDelimiter = "|"
+
+// SetCategoryDelimiter sets the alternate_category separator, for datasets
+// that use something other than "|".
+func SetCategoryDelimiter(delimiter string) {
+	categoryDelimiter = delimiter
+}
+
+// alternateCategories splits an alternate_category value on
+// categoryDelimiter, trimming each category.
+func alternateCategories(value string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for cat := range strings.SplitSeq(value, categoryDelimiter) {
+			if !yield(strings.TrimSpace(cat)) {
+				return
+			}
+		}
+	}
+}
+
+// splitCategories flattens the main and delimited alternate categories of a
+// POI into a single slice, main category first.
+func splitCategories(mainCategory sql.NullString, alternateCategory sql.NullString) []string {
+	categories := make([]string, 0)
+	if mainCategory.Valid {
+		categories = append(categories, mainCategory.String)
+	}
+	if alternateCategory.Valid {
+		categories = slices.AppendSeq(categories, alternateCategories(alternateCategory.String))
+	}
+	return categories
+}
+
+// hasCategoryMatch reports whether any of items, normalised as by
+// parseCategories, is one of categories.
 func hasCategoryMatch(items []string, categories map[string]struct{}) bool {
+	for _, item := range items {
+		if _, exists := categories[strings.ToLower(strings.TrimSpace(item))]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExactCategoryMatch reports whether any of items is exactly one of
+// categories, for filters parsed with exact set.
+func hasExactCategoryMatch(items []string, categories map[string]struct{}) bool {
 	for _, item := range items {
 		if _, exists := categories[item]; exists {
 			return true
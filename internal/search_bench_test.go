@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// benchmarkPOIs is the size of the generated dataset the search benchmarks
+// run over, spread evenly across Great Britain.
+const benchmarkPOIs = 50000
+
+var benchmarkBBoxes = []struct {
+	name string
+	bbox string
+}{
+	{"Small", "-1.62,54.96,-1.58,55.00"},
+	{"Medium", "-2.0,54.5,-1.0,55.5"},
+	{"Large", "-5.0,51.0,1.0,56.0"},
+}
+
+func newBenchmarkSearch(b *testing.B, indexed bool) *gin.Engine {
+	b.Helper()
+
+	db, err := testutil.NewDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+	if err := testutil.Seed(db, testutil.Generate(benchmarkPOIs)); err != nil {
+		b.Fatal(err)
+	}
+
+	previous := spatialIndex
+	b.Cleanup(func() { spatialIndex = previous })
+	spatialIndex = SpatialIndex{Reason: "not enabled"}
+	if indexed {
+		if err := testutil.AddSpatialIndex(db); err != nil {
+			b.Fatal(err)
+		}
+		if err := UseSpatialIndex(db); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.GET("/search", Search(db, SearchConfig{}))
+	return r
+}
+
+func benchmarkSearch(b *testing.B, indexed bool) {
+	for _, tc := range benchmarkBBoxes {
+		b.Run(tc.name, func(b *testing.B) {
+			r := newBenchmarkSearch(b, indexed)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?bbox="+tc.bbox, nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearchTableScan(b *testing.B) {
+	benchmarkSearch(b, false)
+}
+
+func BenchmarkSearchRTree(b *testing.B) {
+	benchmarkSearch(b, true)
+}
@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fixturesBBox encloses every testutil fixture with coordinates.
+const fixturesBBox = "-1.62,54.96,-1.60,54.99"
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := testutil.NewDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newTestSearch(t *testing.T, cfg SearchConfig) *gin.Engine {
+	t.Helper()
+
+	db := newTestDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", Search(db, cfg))
+	return r
+}
+
+func search(t *testing.T, r *gin.Engine, query string) SearchResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /search?%s: expected status %d, got %d: %s", query, http.StatusOK, w.Code, w.Body)
+	}
+
+	var resp SearchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET /search?%s: %v", query, err)
+	}
+	return resp
+}
+
+func fids(pois []POI) []int {
+	fids := make([]int, len(pois))
+	for i, poi := range pois {
+		fids[i] = poi.Fid
+	}
+	return fids
+}
+
+func TestSearchPagingIsStable(t *testing.T) {
+	r := newTestSearch(t, SearchConfig{})
+
+	// Every fixture but fid 6 is in Newcastle upon Tyne, so the pages split
+	// runs of equal sort keys and only the fid tiebreaker orders them.
+	tests := []struct {
+		sort string
+		want []int
+	}{
+		{"locality", []int{6, 1, 2, 3, 4, 5, 7}},
+		{"-locality", []int{1, 2, 3, 4, 5, 7, 6}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sort, func(t *testing.T) {
+			all := fids(search(t, r, fmt.Sprintf("bbox=%s&sort=%s", fixturesBBox, tc.sort)).Results)
+			if !slices.Equal(all, tc.want) {
+				t.Fatalf("sort=%s gave fids %v, want %v", tc.sort, all, tc.want)
+			}
+
+			for _, limit := range []int{1, 2, 3} {
+				var paged []int
+				for offset := 0; offset < len(tc.want); offset += limit {
+					query := fmt.Sprintf("bbox=%s&sort=%s&limit=%d&offset=%d", fixturesBBox, tc.sort, limit, offset)
+					paged = append(paged, fids(search(t, r, query).Results)...)
+				}
+				if !slices.Equal(paged, tc.want) {
+					t.Errorf("sort=%s in pages of %d gave fids %v, want %v", tc.sort, limit, paged, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxCategories(t *testing.T) {
+	previous := maxCategories
+	t.Cleanup(func() { SetMaxCategories(previous) })
+	SetMaxCategories(3)
+
+	tests := []struct {
+		categories string
+		wantErr    bool
+	}{
+		{"pub,cafe", false},
+		{"pub,cafe,bar", false},
+		{"pub,cafe,bar,bench", true},
+		// Duplicates count once, including those differing only in case.
+		{"pub,cafe,bar,pub,Cafe", false},
+	}
+
+	r := newTestSearch(t, SearchConfig{})
+	for _, tc := range tests {
+		t.Run(tc.categories, func(t *testing.T) {
+			categories, err := parseCategories(tc.categories)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("parseCategories(%q) = %v, %v", tc.categories, categories, err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?bbox="+fixturesBBox+"&categories="+tc.categories, nil))
+			wantStatus := http.StatusOK
+			if tc.wantErr {
+				wantStatus = http.StatusBadRequest
+			}
+			if w.Code != wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", wantStatus, w.Code, w.Body)
+			}
+			if tc.wantErr && !strings.Contains(w.Body.String(), "at most 3 categories") {
+				t.Errorf("unexpected error response: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestSearchSkipsNullCoordinates(t *testing.T) {
+	r := newTestSearch(t, SearchConfig{})
+
+	// fid 8 has no coordinates, but shares its H3 cell with fid 1.
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"h3_parent", "h3_parent=8f197322dc090c6", []int{1}},
+		{"bbox at 0,0", "bbox=-0.01,-0.01,0.01,0.01", []int{}},
+		{"bbox", "bbox=" + fixturesBBox, []int{1, 2, 3, 4, 5, 6, 7}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fids(search(t, r, tc.query).Results); !slices.Equal(got, tc.want) {
+				t.Errorf("%s gave fids %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchCategoryExact(t *testing.T) {
+	db := newTestDB(t)
+	pub := "Pub"
+	err := testutil.Seed(db, []testutil.Fixture{{
+		Id: "08f194ad32c2a009", MainCategory: &pub, Source: "test", SourceRecordId: "9", Lat: 54.97, Long: -1.61,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", Search(db, SearchConfig{}))
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"categories=pub", []int{1, 9}},
+		{"categories=PUB", []int{1, 9}},
+		{"categories=pub&category_exact=false", []int{1, 9}},
+		{"categories=pub&category_exact=true", []int{1}},
+		{"categories=Pub&category_exact=true", []int{9}},
+		{"categories=PUB&category_exact=true", []int{}},
+		{"categories=bar&category_exact=true", []int{1, 3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			if got := fids(search(t, r, "bbox="+fixturesBBox+"&"+tc.query).Results); !slices.Equal(got, tc.want) {
+				t.Errorf("%s gave fids %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?bbox="+fixturesBBox+"&categories=pub&category_exact=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("category_exact=maybe: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSearchExplainOutsideDevCountsTotal(t *testing.T) {
+	// explain is only honoured with --dev; otherwise it must not change the
+	// response, including the total of a paged search.
+	r := newTestSearch(t, SearchConfig{})
+
+	resp := search(t, r, "bbox="+fixturesBBox+"&limit=2&explain=true")
+	if got := fids(resp.Results); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("explain=true gave fids %v, want [1 2]", got)
+	}
+	if resp.Total != 7 {
+		t.Errorf("explain=true gave total %d, want 7", resp.Total)
+	}
+}
+
+func TestSearchPagingWithoutGeoPackageContents(t *testing.T) {
+	// The count cache is keyed on gpkg_contents' last_change, but a database
+	// without the table must still page.
+	db := newTestDB(t)
+	if _, err := db.Exec(`DROP TABLE gpkg_contents`); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", Search(db, SearchConfig{}))
+
+	resp := search(t, r, "bbox="+fixturesBBox+"&limit=2")
+	if got := fids(resp.Results); !slices.Equal(got, []int{1, 2}) || resp.Total != 7 {
+		t.Errorf("got fids %v, total %d: want [1 2], total 7", got, resp.Total)
+	}
+}
+
+func TestSearchOutsideDataset(t *testing.T) {
+	attribution := []string{"Test dataset attribution"}
+	r := newTestSearch(t, SearchConfig{Attribution: func() []string { return attribution }})
+	const outside = "bbox=10,10,11,11"
+
+	t.Run("results", func(t *testing.T) {
+		resp := search(t, r, outside)
+		if resp.Results == nil || len(resp.Results) != 0 || !resp.Clamped {
+			t.Errorf("expected clamped, empty results, got %+v", resp)
+		}
+		if resp.Meta == nil || !slices.Equal(resp.Meta.Attribution, attribution) {
+			t.Errorf("expected meta attribution %v, got %+v", attribution, resp.Meta)
+		}
+	})
+
+	t.Run("points", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+outside+"&format=points", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "[]" {
+			t.Errorf("expected status %d and [], got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		var resp struct {
+			Results map[string]POI `json:"results"`
+			Meta    *SearchMeta    `json:"meta"`
+		}
+		getJSON(t, r, "/search?"+outside+"&shape=map", &resp)
+		if resp.Results == nil || len(resp.Results) != 0 {
+			t.Errorf("expected an empty results object, got %v", resp.Results)
+		}
+		if resp.Meta == nil || !slices.Equal(resp.Meta.Attribution, attribution) {
+			t.Errorf("expected meta attribution %v, got %+v", attribution, resp.Meta)
+		}
+	})
+}
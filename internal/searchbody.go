@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchPost runs search with its parameters given as a JSON object instead
+// of a query string, for queries too long for a URL, such as those with many
+// categories. The bbox is a GeoJSON bbox array, [west, south, east, north]
+// (or an array of them), and categories an array of strings; every other
+// search parameter is given as a string, number or boolean. The body is
+// translated into the query string search validates, so both entry points
+// accept exactly the same parameters.
+func SearchPost(search gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body map[string]any
+		dec := json.NewDecoder(c.Request.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+
+		query, err := searchBodyQuery(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// search reads its parameters with c.Query, which caches the query
+		// string on first use, so nothing before this point may call it.
+		c.Request.URL.RawQuery = query.Encode()
+		search(c)
+	}
+}
+
+// searchBodyQuery converts a JSON search body into search's query parameters.
+func searchBodyQuery(body map[string]any) (url.Values, error) {
+	query := url.Values{}
+	for name, value := range body {
+		if !slices.Contains(searchParams, name) {
+			return nil, fmt.Errorf("unknown field '%s'", name)
+		}
+
+		switch name {
+		case "bbox":
+			bboxes, err := bodyBBoxes(value)
+			if err != nil {
+				return nil, err
+			}
+			query[name] = bboxes
+		case "categories":
+			categories, err := bodyStrings(name, value)
+			if err != nil {
+				return nil, err
+			}
+			query.Set(name, strings.Join(categories, ","))
+		default:
+			scalar, err := bodyScalar(name, value)
+			if err != nil {
+				return nil, err
+			}
+			query.Set(name, scalar)
+		}
+	}
+	return query, nil
+}
+
+// bodyBBoxes converts a GeoJSON bbox array, or an array of them, into bbox
+// parameter values.
+func bodyBBoxes(value any) ([]string, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("bbox must be an array of [west, south, east, north]")
+	}
+
+	if _, nested := items[0].([]any); !nested {
+		bbox, err := bodyBBox(items)
+		if err != nil {
+			return nil, err
+		}
+		return []string{bbox}, nil
+	}
+
+	bboxes := make([]string, 0, len(items))
+	for _, item := range items {
+		coords, ok := item.([]any)
+		if !ok {
+			return nil, fmt.Errorf("bbox must be an array of [west, south, east, north]")
+		}
+		bbox, err := bodyBBox(coords)
+		if err != nil {
+			return nil, err
+		}
+		bboxes = append(bboxes, bbox)
+	}
+	return bboxes, nil
+}
+
+func bodyBBox(coords []any) (string, error) {
+	parts := make([]string, 0, len(coords))
+	for _, coord := range coords {
+		number, ok := coord.(json.Number)
+		if !ok {
+			return "", fmt.Errorf("bbox values must be numbers")
+		}
+		parts = append(parts, number.String())
+	}
+	// The length is checked, with the values, by parseBBox.
+	return strings.Join(parts, ","), nil
+}
+
+// bodyStrings accepts an array of strings or, as in the query string, a
+// single comma-separated string.
+func bodyStrings(name string, value any) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of strings", name)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func bodyScalar(name string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s must be a string, number or boolean", name)
+	}
+}
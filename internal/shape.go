@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchMapResponse is a search response with ?shape=map, its results keyed
+// by POI id for clients that index them that way.
+type SearchMapResponse struct {
+	Results map[string]POI `json:"results"`
+	// The embedded Results array is shadowed by the map above; inline has
+	// MessagePack flatten the embedded fields as encoding/json does.
+	SearchResponse `json:",inline"`
+}
+
+func parseShape(value string) (bool, error) {
+	switch value {
+	case "", "array":
+		return false, nil
+	case "map":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid shape value '%s': must be 'array' or 'map'", value)
+	}
+}
+
+// respondSearchMap writes resp with its results keyed by id. Ids are not
+// unique in every dataset (see ?group_by=id), so the last POI with an id
+// wins, in result order, and a warning says how many were dropped. JSON:API
+// documents have a shape of their own, so are written as usual.
+func respondSearchMap(c *gin.Context, resp SearchResponse) {
+	if wantsJSONAPI(c) {
+		respondSearch(c, resp)
+		return
+	}
+
+	byID := make(map[string]POI, len(resp.Results))
+	for _, poi := range resp.Results {
+		byID[poi.Id] = poi
+	}
+	if dropped := len(resp.Results) - len(byID); dropped > 0 {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("dropped %d POI(s) with a duplicate id", dropped))
+	}
+
+	mapped := SearchMapResponse{Results: byID, SearchResponse: resp}
+	mapped.SearchResponse.Results = nil
+	if wantsMsgpack(c) {
+		renderMsgpack(c, http.StatusOK, mapped)
+		return
+	}
+	c.JSON(http.StatusOK, mapped)
+}
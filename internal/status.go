@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kofalt/go-memoize"
+)
+
+type DatabaseStatus struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+	RowCount  int    `json:"row_count"`
+	// Categories is the number of distinct categories precomputed for ref-data.
+	Categories int    `json:"categories"`
+	LastChange string `json:"last_change"`
+	RefDataAge int    `json:"ref_data_age_seconds"`
+}
+
+type UnsplashStatus struct {
+	Configured  bool       `json:"configured"`
+	Keys        int        `json:"keys"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastError   *string    `json:"last_error"`
+	LastErrorAt *time.Time `json:"last_error_at"`
+}
+
+type StatusResponse struct {
+	Database DatabaseStatus `json:"database"`
+	Unsplash UnsplashStatus `json:"unsplash"`
+	Caches   map[string]int `json:"caches"`
+}
+
+// upstreamStatus remembers the outcome of the most recent calls to an
+// upstream API.
+type upstreamStatus struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+var unsplashCalls upstreamStatus
+
+func (u *upstreamStatus) record(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err != nil {
+		u.lastError = err.Error()
+		u.lastErrorAt = time.Now()
+	} else {
+		u.lastSuccess = time.Now()
+	}
+}
+
+var trackedCaches sync.Map
+
+// TrackCache registers a cache under name, so its size is reported by the
+// status endpoint.
+func TrackCache(name string, cache *memoize.Memoizer) *memoize.Memoizer {
+	trackedCaches.Store(name, cache)
+	return cache
+}
+
+// Status summarises the health of the service's dependencies for operators,
+// from state that's already tracked. Other than a ping of the database, it
+// does no work of its own.
+func Status(db *sql.DB, summaries *SummaryCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, age := summaries.Get()
+
+		resp := StatusResponse{
+			Database: DatabaseStatus{
+				Reachable:  true,
+				RowCount:   summary.Count,
+				Categories: len(summary.Categories),
+				LastChange: summary.LastUpdated,
+				RefDataAge: int(age.Seconds()),
+			},
+			Unsplash: unsplashStatus(),
+			Caches:   map[string]int{},
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			resp.Database.Reachable = false
+			resp.Database.Error = err.Error()
+		}
+
+		trackedCaches.Range(func(name, cache any) bool {
+			resp.Caches[name.(string)] = cache.(*memoize.Memoizer).Storage.ItemCount()
+			return true
+		})
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func unsplashStatus() UnsplashStatus {
+	keys := accessKeys()
+	status := UnsplashStatus{
+		Configured: keys.configured(),
+		Keys:       keys.size(),
+	}
+	if !status.Configured {
+		status.Keys = 0
+	}
+
+	unsplashCalls.mu.Lock()
+	defer unsplashCalls.mu.Unlock()
+
+	if !unsplashCalls.lastSuccess.IsZero() {
+		lastSuccess := unsplashCalls.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if !unsplashCalls.lastErrorAt.IsZero() {
+		lastError, lastErrorAt := unsplashCalls.lastError, unsplashCalls.lastErrorAt
+		status.LastError = &lastError
+		status.LastErrorAt = &lastErrorAt
+	}
+
+	return status
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const streamBatchSize = 100
+
+type streamQuery struct {
+	seq        int
+	bbox       []float64
+	categories map[string]struct{}
+}
+
+type streamSession struct {
+	mu      sync.Mutex
+	seq     int
+	updates chan streamQuery
+}
+
+// push queues q as the session's next query, replacing any query not yet
+// picked up: a slow client only ever receives the latest viewport.
+func (s *streamSession) push(bbox []float64, categories map[string]struct{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	select {
+	case <-s.updates:
+	default:
+	}
+	s.updates <- streamQuery{seq: s.seq, bbox: bbox, categories: categories}
+	return s.seq
+}
+
+// requeue puts back a query taken off the queue, unless push has queued a
+// newer one since.
+func (s *streamSession) requeue(q streamQuery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case s.updates <- q:
+	default:
+	}
+}
+
+// SearchStreams serves live search over Server-Sent Events. A client opens
+// the stream, receives a session id, then POSTs bbox updates for that session
+// as the map pans. Each update cancels the query in flight and streams the
+// new results in batches.
+type SearchStreams struct {
+	db       *sql.DB
+	mu       sync.Mutex
+	sessions map[string]*streamSession
+}
+
+type StreamBatch struct {
+	Seq     int   `json:"seq"`
+	Results []POI `json:"results"`
+}
+
+type StreamDone struct {
+	Seq   int `json:"seq"`
+	Count int `json:"count"`
+}
+
+func NewSearchStreams(db *sql.DB) *SearchStreams {
+	return &SearchStreams{db: db, sessions: make(map[string]*streamSession)}
+}
+
+// Stream opens the event stream. It emits a "session" event carrying the id
+// to POST updates to, then "batch" events of results and a "done" event per
+// completed query. A superseded query emits no "done".
+func (s *SearchStreams) Stream(c *gin.Context) {
+	id, err := newSessionID()
+	if err != nil {
+		log.Printf("error creating stream session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return
+	}
+
+	session := &streamSession{updates: make(chan streamQuery, 1)}
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}()
+
+	// An initial bbox may be supplied when opening the stream.
+	if bboxStr := c.Query("bbox"); bboxStr != "" {
+		bbox, err := parseBBox(bboxStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		categories, err := parseCategories(c.Query("categories"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		session.push(bbox, categories)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("session", gin.H{"id": id})
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-session.updates:
+			if err := s.run(ctx, c, session, q); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					log.Printf("error streaming search: %v", err)
+					c.SSEvent("error", gin.H{"seq": q.seq, "error": "An internal server error occurred"})
+					c.Writer.Flush()
+				}
+			}
+		}
+	}
+}
+
+// run streams the results of q, abandoning it as soon as a newer query is
+// queued for the session: the query is cancelled, even mid-batch, and the
+// newer query put back for the caller to run next.
+func (s *SearchStreams) run(ctx context.Context, c *gin.Context, session *streamSession, q streamQuery) error {
+	queryCtx, cancel := context.WithCancel(ctx)
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-queryCtx.Done():
+		case next := <-session.updates:
+			session.requeue(next)
+			cancel()
+		}
+	}()
+	defer func() {
+		cancel()
+		<-watched
+	}()
+
+	// A cancelled query fails with whatever error SQLite was interrupted
+	// with, so report it as the cancellation it is.
+	abandoned := func(err error) error {
+		if queryCtx.Err() != nil {
+			return context.Canceled
+		}
+		return err
+	}
+
+	rows, err := s.db.QueryContext(queryCtx,
+		"SELECT "+columnList(selectedColumns()...)+" FROM "+table()+" WHERE "+bboxClause()+" ORDER BY "+column("fid"),
+		bboxArgs(q.bbox)...,
+	)
+	if err != nil {
+		return abandoned(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v", err)
+		}
+	}()
+
+	count := 0
+	batch := make([]POI, 0, streamBatchSize)
+	flush := func() error {
+		if queryCtx.Err() != nil {
+			return context.Canceled
+		}
+		c.SSEvent("batch", StreamBatch{Seq: q.seq, Results: batch})
+		c.Writer.Flush()
+		count += len(batch)
+		batch = make([]POI, 0, streamBatchSize)
+		return nil
+	}
+
+	for rows.Next() {
+		poi, err := scanPOI(rows, scanOptions{})
+		if err != nil {
+			return abandoned(err)
+		}
+
+		if len(q.categories) > 0 && !hasCategoryMatch(poi.Categories, q.categories) {
+			continue
+		}
+
+		batch = append(batch, poi)
+		if len(batch) == streamBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return abandoned(err)
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if queryCtx.Err() != nil {
+		return context.Canceled
+	}
+	c.SSEvent("done", StreamDone{Seq: q.seq, Count: count})
+	c.Writer.Flush()
+	return nil
+}
+
+// Update queues a new bbox (and optional categories) for a stream session,
+// returning the sequence number its events will carry.
+func (s *SearchStreams) Update(c *gin.Context) {
+	s.mu.Lock()
+	session, exists := s.sessions[c.Param("session")]
+	s.mu.Unlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stream session not found"})
+		return
+	}
+
+	bbox, err := parseBBox(c.Query("bbox"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories, err := parseCategories(c.Query("categories"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"seq": session.push(bbox, categories)})
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var searchParams = []string{
+	"bbox", "h3_parent", "snap", "categories", "taxonomy", "named_only", "postcode",
+	"per_category_limit", "sample", "limit", "offset", "min_confidence", "sort",
+	"include_bounds", "skip_errors", "geom_format", "group_by", "explain", "h3_resolution",
+	"category_detail", "include_lsoa_name", "category_exact", "format", "dedupe", "shape", "zoom",
+	"q", "lat", "long", "highlight", "highlight_start", "highlight_end",
+}
+
+// globalParams are accepted by every route, being handled by middleware.
+var globalParams = []string{"pretty"}
+
+// knownParams lists the query parameters each route accepts. Routes without
+// an entry are not checked.
+var knownParams = map[string][]string{
+	"/v1/geods-poi/search":                 searchParams,
+	"/v1/geods-poi/map-init":               searchParams,
+	"/v1/geods-poi/search/stream":          {"bbox", "categories"},
+	"/v1/geods-poi/search/stream/:session": {"bbox", "categories"},
+	"/v1/geods-poi/autocomplete":           {"q", "bbox", "limit", "highlight", "highlight_start", "highlight_end"},
+	"/v1/geods-poi/changes":                {"since", "cursor", "limit"},
+	"/v1/geods-poi/extent":                 {"category", "shape"},
+	"/v1/geods-poi/export":                 {"bbox", "categories", "format"},
+	"/v1/geods-poi/nearest":                {"lat", "long", "n", "categories"},
+	"/v1/geods-poi/coverage":               {"bbox", "resolution", "category"},
+	"/v1/geods-poi/h3/cover":               {"bbox", "resolution"},
+	"/v1/geods-poi/h3/occupied":            {"bbox", "resolution"},
+	"/v1/geods-poi/overview":               {"bbox", "cols", "rows"},
+	"/v1/geods-poi/estimate":               {"bbox", "categories"},
+	"/v1/geods-poi/ref-data":               {"taxonomy"},
+	"/v1/geods-poi/ref-data/top":           {"limit", "ties"},
+	"/v1/geods-poi/ref-data/values":        {"field", "q", "limit", "offset"},
+	"/v1/geods-poi/ref-data/crosstab":      {"by"},
+	"/v1/geods-poi/image/:category":        {"orientation", "size"},
+	"/v1/geods-poi/image/:category/raw":    {"orientation", "size"},
+	"/v1/geods-poi/image/:category/track":  {"orientation"},
+	"/v1/geods-poi/category/:category":     {"orientation", "size"},
+}
+
+// StrictParams rejects requests with query parameters the route doesn't
+// accept, so a misspelled filter fails loudly instead of being ignored.
+func StrictParams() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, ok := knownParams[c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		unknown := make([]string, 0)
+		for param := range c.Request.URL.Query() {
+			if !slices.Contains(allowed, param) && !slices.Contains(globalParams, param) {
+				unknown = append(unknown, param)
+			}
+		}
+		if len(unknown) == 0 {
+			c.Next()
+			return
+		}
+
+		slices.Sort(unknown)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("unknown query parameters: %s", strings.Join(unknown, ", ")),
+			"unknown": unknown,
+			"allowed": allowed,
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// SummaryCache holds the reference data summary with stale-while-revalidate
+// semantics: past the soft TTL the cached summary is still served while it is
+// recomputed in the background, and only past the hard TTL do callers wait
+// for a fresh one. Either way only one recompute runs at a time, and one
+// that fails keeps the summary it would have replaced.
+type SummaryCache struct {
+	db         *sql.DB
+	summarize  func(*sql.DB) (*Summary, error)
+	softTTL    time.Duration
+	hardTTL    time.Duration
+	mu         sync.Mutex
+	summary    *Summary
+	computedAt time.Time
+	// refreshed is closed when the recompute in progress finishes, and is
+	// nil when none is.
+	refreshed chan struct{}
+}
+
+// NewSummaryCache starts a cache from an already computed summary.
+func NewSummaryCache(db *sql.DB, summary *Summary, softTTL time.Duration, hardTTL time.Duration) *SummaryCache {
+	return &SummaryCache{
+		db:         db,
+		summarize:  Summarize,
+		softTTL:    softTTL,
+		hardTTL:    hardTTL,
+		summary:    summary,
+		computedAt: time.Now(),
+	}
+}
+
+// Get returns the summary and how long ago it was computed.
+func (s *SummaryCache) Get() (*Summary, time.Duration) {
+	s.mu.Lock()
+	age := time.Since(s.computedAt)
+	if age < s.softTTL {
+		defer s.mu.Unlock()
+		return s.summary, age
+	}
+
+	refreshed := s.refreshed
+	if refreshed == nil {
+		if age >= s.hardTTL {
+			log.Printf("Reference data is %s old, recomputing", age.Round(time.Second))
+		} else {
+			log.Println("Refreshing reference data in the background")
+		}
+		refreshed = make(chan struct{})
+		s.refreshed = refreshed
+		go s.refresh(refreshed)
+	}
+	if age < s.hardTTL {
+		defer s.mu.Unlock()
+		return s.summary, age
+	}
+	s.mu.Unlock()
+
+	<-refreshed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary, time.Since(s.computedAt)
+}
+
+func (s *SummaryCache) refresh(refreshed chan struct{}) {
+	defer close(refreshed)
+	summary, err := s.summarize(s.db)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshed = nil
+	if err != nil {
+		log.Printf("WARNING: error recomputing reference data, keeping the previous summary: %v", err)
+		return
+	}
+	s.summary = summary
+	s.computedAt = time.Now()
+}
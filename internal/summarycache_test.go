@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSummaryCacheKeepsSummaryWhenRecomputeFails(t *testing.T) {
+	db := newTestDB(t)
+	summary, err := Summarize(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DROP TABLE poi_uk`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Summarize(db); err == nil {
+		t.Fatal("expected Summarize to fail without the POI table")
+	}
+
+	t.Run("hard TTL", func(t *testing.T) {
+		summaries := NewSummaryCache(db, summary, 0, 0)
+		if got, _ := summaries.Get(); got != summary {
+			t.Errorf("got summary %+v, want the previous %+v", got, summary)
+		}
+	})
+
+	t.Run("background", func(t *testing.T) {
+		summaries := NewSummaryCache(db, summary, 0, time.Hour)
+		if got, _ := summaries.Get(); got != summary {
+			t.Errorf("got summary %+v, want the stale %+v", got, summary)
+		}
+		summaries.mu.Lock()
+		refreshed := summaries.refreshed
+		summaries.mu.Unlock()
+		<-refreshed
+
+		summaries.mu.Lock()
+		defer summaries.mu.Unlock()
+		if got := summaries.summary; got != summary {
+			t.Errorf("after the refresh failed got summary %+v, want the previous %+v", got, summary)
+		}
+	})
+}
+
+func TestSummaryCacheRecomputesOnce(t *testing.T) {
+	previous, fresh := &Summary{Count: 1}, &Summary{Count: 2}
+	summaries := NewSummaryCache(nil, previous, time.Hour, 2*time.Hour)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	summaries.summarize = func(*sql.DB) (*Summary, error) {
+		calls.Add(1)
+		<-release
+		return fresh, nil
+	}
+
+	// Past the soft TTL, callers get the stale summary without waiting for
+	// the refresh.
+	summaries.computedAt = time.Now().Add(-90 * time.Minute)
+	for range 3 {
+		if got, _ := summaries.Get(); got != previous {
+			t.Fatalf("got summary %+v, want the stale %+v", got, previous)
+		}
+	}
+
+	// Past the hard TTL, they wait for the refresh already running rather
+	// than starting their own.
+	summaries.mu.Lock()
+	summaries.computedAt = time.Now().Add(-3 * time.Hour)
+	summaries.mu.Unlock()
+	var wg sync.WaitGroup
+	got := make([]*Summary, 5)
+	for i := range got {
+		wg.Go(func() { got[i], _ = summaries.Get() })
+	}
+	close(release)
+	wg.Wait()
+
+	for i, summary := range got {
+		if summary != fresh {
+			t.Errorf("caller %d got summary %+v, want %+v", i, summary, fresh)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("summary recomputed %d times, want once", n)
+	}
+}
+
+func TestSummarizeReportsFailure(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec(`DROP TABLE gpkg_contents`); err != nil {
+		t.Fatal(err)
+	}
+	// gpkg_contents is optional: its absence only degrades the summary.
+	summary, err := Summarize(db)
+	if err != nil {
+		t.Errorf("expected no error without gpkg_contents, got %v", err)
+	}
+	if summary.Count != 8 || summary.LastUpdated != "unknown" {
+		t.Errorf("got count %d, last updated %s: want 8, unknown", summary.Count, summary.LastUpdated)
+	}
+
+	if _, err := db.Exec(`DROP TABLE poi_uk`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Summarize(db); err == nil {
+		t.Error("expected an error without the POI table")
+	}
+}
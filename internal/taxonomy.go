@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+)
+
+// otherCategory is the simple category for anything the taxonomy doesn't map.
+const otherCategory = "other"
+
+// Taxonomy collapses the fine-grained source categories into a small display
+// taxonomy, mapping each category onto the simple categories it belongs to.
+type Taxonomy map[string][]string
+
+// LoadTaxonomy reads a JSON object of simple categories, each listing the
+// source categories it covers; a category may appear under more than one. A
+// missing file yields no taxonomy, so ?taxonomy=simple is rejected.
+func LoadTaxonomy(path string) (Taxonomy, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("No category taxonomy found at %s", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading category taxonomy: %w", err)
+	}
+
+	var groups map[string][]string
+	if err := json.Unmarshal(contents, &groups); err != nil {
+		return nil, fmt.Errorf("error parsing category taxonomy: %w", err)
+	}
+
+	taxonomy := make(Taxonomy)
+	for _, group := range slices.Sorted(maps.Keys(groups)) {
+		for _, category := range groups[group] {
+			taxonomy[category] = append(taxonomy[category], group)
+		}
+	}
+
+	log.Printf("Loaded %d simple categories covering %d categories from %s", len(groups), len(taxonomy), path)
+	return taxonomy, nil
+}
+
+// translate maps categories onto their simple categories, in order and
+// without duplicates.
+func (t Taxonomy) translate(categories []string) []string {
+	simple := make([]string, 0, len(categories))
+	for _, category := range categories {
+		groups, ok := t[category]
+		if !ok {
+			groups = []string{otherCategory}
+		}
+		for _, group := range groups {
+			if !slices.Contains(simple, group) {
+				simple = append(simple, group)
+			}
+		}
+	}
+	return simple
+}
+
+// translateCounts totals category counts by simple category. A POI whose
+// categories fall in the same simple category is counted once per category,
+// so the totals are occurrences rather than distinct POIs.
+func (t Taxonomy) translateCounts(counts map[string]int) map[string]int {
+	simple := make(map[string]int)
+	for category, count := range counts {
+		for _, group := range t.translate([]string{category}) {
+			simple[group] += count
+		}
+	}
+	return simple
+}
+
+// parseTaxonomy validates the taxonomy parameter, reporting whether the
+// simple taxonomy was requested.
+func parseTaxonomy(value string, taxonomy Taxonomy) (bool, error) {
+	switch value {
+	case "":
+		return false, nil
+	case "simple":
+		if taxonomy == nil {
+			return false, fmt.Errorf("the simple taxonomy is not available")
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid taxonomy value '%s': must be 'simple'", value)
+	}
+}
@@ -0,0 +1,296 @@
+// Package testutil builds small, GeoPackage-shaped SQLite databases so the
+// handlers can be exercised against real SQL without the full POI dataset.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+)
+
+// Fixture is a single row of the poi_uk table.
+type Fixture struct {
+	Id                string
+	PrimaryName       *string
+	MainCategory      *string
+	AlternateCategory *string
+	Address           *string
+	Locality          *string
+	Postcode          *string
+	Region            *string
+	Country           *string
+	Source            string
+	SourceRecordId    string
+	Lat               float64
+	Long              float64
+	H3_15             string
+	Easting           float64
+	Northing          float64
+	LSOA21CD          string
+	// NoCoordinates stores the row with NULL lat, long and geom.
+	NoCoordinates bool
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// Fixtures is a handful of points of interest around central Newcastle.
+var Fixtures = []Fixture{
+	{
+		Id: "08f194ad32c2a001", PrimaryName: ptr("The Crown Posada"), MainCategory: ptr("pub"), AlternateCategory: ptr("bar|restaurant"),
+		Address: ptr("31 The Side"), Locality: ptr("Newcastle upon Tyne"), Postcode: ptr("NE1 3JE"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100001", Lat: 54.9689, Long: -1.6040, H3_15: "8f197322dc090c6",
+		Easting: 425186, Northing: 563920, LSOA21CD: "E01008397",
+	},
+	{
+		Id: "08f194ad32c2a002", PrimaryName: ptr("Grey's Monument Cafe"), MainCategory: ptr("cafe"), AlternateCategory: ptr("coffee_shop"),
+		Address: ptr("1 Grey Street"), Locality: ptr("Newcastle upon Tyne"), Postcode: ptr("NE1 6EE"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100002", Lat: 54.9741, Long: -1.6132, H3_15: "8f197322cadb091",
+		Easting: 424590, Northing: 564485, LSOA21CD: "E01033553",
+	},
+	{
+		Id: "08f194ad32c2a003", PrimaryName: nil, MainCategory: ptr("bar"), AlternateCategory: nil,
+		Address: nil, Locality: ptr("Newcastle upon Tyne"), Postcode: nil, Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "microsoft", SourceRecordId: "200003", Lat: 54.9701, Long: -1.6086, H3_15: "8f197322dcae232",
+		Easting: 424886, Northing: 564042, LSOA21CD: "E01008397",
+	},
+	{
+		Id: "08f194ad32c2a004", PrimaryName: ptr("Tesco Express"), MainCategory: ptr("supermarket"), AlternateCategory: ptr("grocery_store|convenience_store"),
+		Address: ptr("10 Northumberland Street"), Locality: ptr("Newcastle upon Tyne"), Postcode: ptr("NE1 7DE"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100004", Lat: 54.9778, Long: -1.6178, H3_15: "8f197322c06cd03",
+		Easting: 424295, Northing: 564896, LSOA21CD: "E01033553",
+	},
+	{
+		Id: "08f194ad32c2a005", PrimaryName: ptr("Theatre Royal"), MainCategory: ptr("theatre"), AlternateCategory: ptr("arts_and_entertainment"),
+		Address: ptr("100 Grey Street"), Locality: ptr("Newcastle upon Tyne"), Postcode: ptr("NE1 6BR"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100005", Lat: 54.9732, Long: -1.6139, H3_15: "8f197322c346d32",
+		Easting: 424546, Northing: 564385, LSOA21CD: "E01033553",
+	},
+	{
+		Id: "08f194ad32c2a006", PrimaryName: ptr("Gateshead Millennium Bridge"), MainCategory: ptr("landmark_and_historical_building"), AlternateCategory: nil,
+		Address: nil, Locality: ptr("Gateshead"), Postcode: ptr("NE8 2JF"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100006", Lat: 54.9664, Long: -1.6019, H3_15: "8f197322d1a4aad",
+		Easting: 425322, Northing: 563643, LSOA21CD: "E01008162",
+	},
+	{
+		Id: "08f194ad32c2a007", PrimaryName: ptr("Civic Centre"), MainCategory: ptr("public_service_and_government"), AlternateCategory: ptr("town_hall"),
+		Address: ptr("Barras Bridge"), Locality: ptr("Newcastle upon Tyne"), Postcode: ptr("NE1 8QH"), Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "microsoft", SourceRecordId: "200007", Lat: 54.9817, Long: -1.6158, H3_15: "8f197322c8c80f0",
+		Easting: 424418, Northing: 565331, LSOA21CD: "E01033553",
+	},
+	{
+		Id: "08f194ad32c2a008", PrimaryName: ptr("The Unlocated Arms"), MainCategory: ptr("pub"), AlternateCategory: nil,
+		Address: nil, Locality: ptr("Newcastle upon Tyne"), Postcode: nil, Region: ptr("ENG"), Country: ptr("GB"),
+		Source: "meta", SourceRecordId: "100008", H3_15: "8f197322dc090c6",
+		LSOA21CD: "E01008397", NoCoordinates: true,
+	},
+}
+
+const schema = `
+	CREATE TABLE gpkg_contents (
+		table_name TEXT NOT NULL PRIMARY KEY,
+		data_type TEXT NOT NULL,
+		identifier TEXT UNIQUE,
+		description TEXT DEFAULT '',
+		last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+		min_x DOUBLE,
+		min_y DOUBLE,
+		max_x DOUBLE,
+		max_y DOUBLE,
+		srs_id INTEGER
+	);
+
+	CREATE TABLE poi_uk (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		geom POINT,
+		id TEXT,
+		primary_name TEXT,
+		main_category TEXT,
+		alternate_category TEXT,
+		address TEXT,
+		locality TEXT,
+		postcode TEXT,
+		region TEXT,
+		country TEXT,
+		source TEXT,
+		source_record_id TEXT,
+		lat REAL,
+		long REAL,
+		h3_15 TEXT,
+		easting REAL,
+		northing REAL,
+		lsoa21cd TEXT
+	);
+`
+
+var dbCounter atomic.Int64
+
+// NewDB creates a fresh in-memory GeoPackage-shaped database seeded with
+// Fixtures. Each call returns an independent database.
+func NewDB() (*sql.DB, error) {
+	return Open("sqlite3", "")
+}
+
+// Open is NewDB through the named database/sql driver, with params (such as
+// "_busy_timeout=5000") added to its DSN, so the fixtures can be served with
+// the same connection settings as a real database.
+func Open(driverName string, params string) (*sql.DB, error) {
+	// A named, shared-cache in-memory database is visible to every connection
+	// in the pool, unlike a bare ":memory:" which is private per connection.
+	dsn := fmt.Sprintf("file:testutil-%d?mode=memory&cache=shared", dbCounter.Add(1))
+	if params != "" {
+		dsn += "&" + params
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening in-memory database: %w", err)
+	}
+
+	// The database only lives as long as at least one connection to it stays
+	// open, and the pool is free to close any of its own, so one is held
+	// back from the pool. It is never released, so the database lasts until
+	// the process exits, which for fixtures is no loss.
+	if _, err := db.Conn(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error connecting to in-memory database: %w", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error creating schema: %w", err)
+	}
+
+	if err := Seed(db, Fixtures); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Seed inserts fixtures into the poi_uk table and records the dataset extent
+// in gpkg_contents.
+func Seed(db *sql.DB, fixtures []Fixture) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, f := range fixtures {
+		var geomBytes []byte
+		var lat, long any = f.Lat, f.Long
+		if f.NoCoordinates {
+			lat, long = nil, nil
+		} else if geomBytes, err = GeoPackagePoint(f.Long, f.Lat, 4326); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO poi_uk (
+				geom, id, primary_name, main_category, alternate_category,
+				address, locality, postcode, region, country, source, source_record_id,
+				lat, long, h3_15, easting, northing, lsoa21cd
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			geomBytes, f.Id, f.PrimaryName, f.MainCategory, f.AlternateCategory,
+			f.Address, f.Locality, f.Postcode, f.Region, f.Country, f.Source, f.SourceRecordId,
+			lat, long, f.H3_15, f.Easting, f.Northing, f.LSOA21CD,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting fixture %s: %w", f.Id, err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO gpkg_contents (table_name, data_type, identifier, description, min_x, min_y, max_x, max_y, srs_id)
+		SELECT 'poi_uk', 'features', 'poi_uk', 'Test fixtures', MIN(long), MIN(lat), MAX(long), MAX(lat), 4326
+		FROM poi_uk`)
+	if err != nil {
+		return fmt.Errorf("error updating gpkg_contents: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing fixtures: %w", err)
+	}
+
+	return nil
+}
+
+// GeoPackagePoint encodes a point as a GeoPackage geometry blob: an 8-byte
+// header (magic, version, flags, SRID) with no envelope, followed by
+// little-endian WKB.
+func GeoPackagePoint(long float64, lat float64, srid int32) ([]byte, error) {
+	point := geom.NewPointFlat(geom.XY, []float64{long, lat})
+	wkbBytes, err := wkb.Marshal(point, binary.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling WKB: %w", err)
+	}
+
+	header := []byte{'G', 'P', 0, 0x01, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], uint32(srid))
+
+	return append(header, wkbBytes...), nil
+}
+
+// generatedCategories are cycled through by Generate.
+var generatedCategories = []string{"pub", "cafe", "bar", "supermarket", "restaurant", "bench", "hospital"}
+
+// Generate returns n fixtures evenly spread over Great Britain, as a larger
+// dataset for benchmarks. The same n always gives the same fixtures.
+func Generate(n int) []Fixture {
+	const left, bottom, right, top = -6.0, 50.0, 2.0, 58.0
+
+	side := int(math.Ceil(math.Sqrt(float64(n))))
+	fixtures := make([]Fixture, n)
+	for i := range fixtures {
+		category := generatedCategories[i%len(generatedCategories)]
+		fixtures[i] = Fixture{
+			Id:             fmt.Sprintf("generated-%d", i),
+			PrimaryName:    ptr(fmt.Sprintf("%s %d", category, i)),
+			MainCategory:   ptr(category),
+			Region:         ptr("ENG"),
+			Country:        ptr("GB"),
+			Source:         "meta",
+			SourceRecordId: fmt.Sprintf("%d", i),
+			Lat:            bottom + (top-bottom)*(float64(i/side)+0.5)/float64(side),
+			Long:           left + (right-left)*(float64(i%side)+0.5)/float64(side),
+			H3_15:          "8f197322dc090c6",
+		}
+	}
+	return fixtures
+}
+
+// AddSpatialIndex builds the GeoPackage R-tree index over the poi_uk
+// geometries, registering the geometry column as gpkg_geometry_columns
+// does, so queries can be run with and without the index.
+func AddSpatialIndex(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS gpkg_geometry_columns (
+			table_name TEXT NOT NULL,
+			column_name TEXT NOT NULL,
+			geometry_type_name TEXT NOT NULL,
+			srs_id INTEGER NOT NULL,
+			z TINYINT NOT NULL,
+			m TINYINT NOT NULL,
+			PRIMARY KEY (table_name, column_name)
+		);
+		INSERT OR REPLACE INTO gpkg_geometry_columns VALUES ('poi_uk', 'geom', 'POINT', 4326, 0, 0);
+		DROP TABLE IF EXISTS rtree_poi_uk_geom;
+		CREATE VIRTUAL TABLE rtree_poi_uk_geom USING rtree(id, minx, maxx, miny, maxy);
+		INSERT INTO rtree_poi_uk_geom SELECT fid, long, long, lat, lat FROM poi_uk WHERE lat IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("error building spatial index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+)
+
+func TestNewDB(t *testing.T) {
+	db, err := NewDB()
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM poi_uk`).Scan(&count); err != nil {
+		t.Fatalf("error counting fixtures: %v", err)
+	}
+	if count != len(Fixtures) {
+		t.Errorf("poi_uk has %d rows, want %d", count, len(Fixtures))
+	}
+
+	var minX, minY, maxX, maxY float64
+	err = db.QueryRow(`SELECT min_x, min_y, max_x, max_y FROM gpkg_contents WHERE table_name = 'poi_uk'`).
+		Scan(&minX, &minY, &maxX, &maxY)
+	if err != nil {
+		t.Fatalf("error reading gpkg_contents: %v", err)
+	}
+	if minX != -1.6178 || minY != 54.9664 || maxX != -1.6019 || maxY != 54.9817 {
+		t.Errorf("extent = %v,%v,%v,%v, want the located fixtures' extent", minX, minY, maxX, maxY)
+	}
+}
+
+func TestNewDBIsIndependent(t *testing.T) {
+	a, err := NewDB()
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer a.Close()
+	b, err := NewDB()
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer b.Close()
+
+	if _, err := a.Exec(`DELETE FROM poi_uk`); err != nil {
+		t.Fatalf("error deleting fixtures: %v", err)
+	}
+
+	var count int
+	if err := b.QueryRow(`SELECT COUNT(*) FROM poi_uk`).Scan(&count); err != nil {
+		t.Fatalf("error counting fixtures: %v", err)
+	}
+	if count != len(Fixtures) {
+		t.Errorf("second database has %d rows after deleting from the first, want %d", count, len(Fixtures))
+	}
+}
+
+func TestNewDBOutlivesIdleConnections(t *testing.T) {
+	db, err := NewDB()
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	// Closing every pooled connection would drop an unanchored in-memory
+	// database.
+	db.SetMaxIdleConns(0)
+	db.SetConnMaxLifetime(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM poi_uk`).Scan(&count); err != nil {
+		t.Fatalf("error counting fixtures after closing idle connections: %v", err)
+	}
+}
+
+func TestNoCoordinatesFixture(t *testing.T) {
+	db, err := NewDB()
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	var located, unlocated int
+	err = db.QueryRow(`SELECT COUNT(lat), COUNT(*) - COUNT(lat) FROM poi_uk`).Scan(&located, &unlocated)
+	if err != nil {
+		t.Fatalf("error counting coordinates: %v", err)
+	}
+	if located != len(Fixtures)-1 || unlocated != 1 {
+		t.Errorf("got %d located and %d unlocated rows, want %d and 1", located, unlocated, len(Fixtures)-1)
+	}
+}
+
+func TestGeoPackagePoint(t *testing.T) {
+	blob, err := GeoPackagePoint(-1.604, 54.9689, 4326)
+	if err != nil {
+		t.Fatalf("GeoPackagePoint() error = %v", err)
+	}
+
+	if string(blob[:2]) != "GP" {
+		t.Errorf("magic = %q, want \"GP\"", blob[:2])
+	}
+	if srid := binary.LittleEndian.Uint32(blob[4:8]); srid != 4326 {
+		t.Errorf("srid = %d, want 4326", srid)
+	}
+
+	g, err := wkb.Unmarshal(blob[8:])
+	if err != nil {
+		t.Fatalf("error decoding WKB: %v", err)
+	}
+	point, ok := g.(*geom.Point)
+	if !ok || point.X() != -1.604 || point.Y() != 54.9689 {
+		t.Errorf("geometry = %v, want POINT (-1.604 54.9689)", g)
+	}
+}
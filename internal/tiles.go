@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"math"
+)
+
+// maxSnapZoom bounds how fine the snapping grid can get; beyond street level
+// the over-fetch saved isn't worth the lost cache hits.
+const maxSnapZoom = 18
+
+// maxMercatorLat is the latitude limit of the Web Mercator tile grid.
+const maxMercatorLat = 85.0511287798
+
+// snapBBox expands bbox outward to the edges of the slippy-map tiles covering
+// it, at a zoom where a tile is roughly the size of the box. Slightly
+// different views of the same area therefore snap to the same bbox.
+func snapBBox(bbox []float64) []float64 {
+	width := bbox[RIGHT] - bbox[LEFT]
+	zoom := maxSnapZoom
+	if width > 0 {
+		zoom = max(0, min(maxSnapZoom, int(math.Floor(math.Log2(360/width)))))
+	}
+
+	n := math.Exp2(float64(zoom))
+	minX := math.Floor(tileX(bbox[LEFT], n))
+	maxX := math.Ceil(tileX(bbox[RIGHT], n))
+	minY := math.Floor(tileY(bbox[TOP], n))
+	maxY := math.Ceil(tileY(bbox[BOTTOM], n))
+
+	// A zero-width edge would collapse onto a single tile boundary.
+	if maxX == minX {
+		maxX++
+	}
+	if maxY == minY {
+		maxY++
+	}
+
+	return []float64{tileLong(minX, n), tileLat(maxY, n), tileLong(maxX, n), tileLat(minY, n)}
+}
+
+func tileX(long float64, n float64) float64 {
+	return (long + 180) / 360 * n
+}
+
+func tileY(lat float64, n float64) float64 {
+	lat = max(-maxMercatorLat, min(maxMercatorLat, lat))
+	rad := lat * math.Pi / 180
+	return (1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * n
+}
+
+func tileLong(x float64, n float64) float64 {
+	return x/n*360 - 180
+}
+
+func tileLat(y float64, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
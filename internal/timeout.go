@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withQueryTimeout bounds a query by the server's statement timeout, which
+// applies however long the client is prepared to wait. Zero means no limit.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryTimedOut reports whether a query failed because its deadline passed.
+// SQLite reports an interrupted statement rather than the context error, so
+// the context is consulted too.
+func queryTimedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+func respondQueryTimeout(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error": "The query took too long to run; try a smaller bbox or fewer filters",
+		"code":  "query_timeout",
+	})
+}
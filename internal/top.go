@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultTopLimit = 20
+
+type RankedCategory struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	// Rank is shared by categories with equal counts (1, 2, 2, 4, ...).
+	Rank int `json:"rank"`
+}
+
+type TopCategoriesResponse struct {
+	Categories []RankedCategory `json:"categories"`
+}
+
+// TopCategories returns the most common categories, most popular first. Ties
+// are broken alphabetically so the order is stable, and share the same rank.
+// With ?ties=true, categories tied with the last one are also included, even
+// if that goes past the limit. The counts are those of the current ref-data
+// summary, so follow it as it's refreshed.
+func TopCategories(summaries *SummaryCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := parseLimit("limit", c.Query("limit"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limit == 0 {
+			limit = defaultTopLimit
+		}
+
+		ties, err := parseBool("ties", c.Query("ties"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		summary, _ := summaries.Get()
+		ranked := rankCategories(summary.Categories)
+		n := min(limit, len(ranked))
+		if ties {
+			for n > 0 && n < len(ranked) && ranked[n].Rank == ranked[n-1].Rank {
+				n++
+			}
+		}
+
+		c.JSON(http.StatusOK, TopCategoriesResponse{Categories: ranked[:n]})
+	}
+}
+
+func rankCategories(categories map[string]int) []RankedCategory {
+	ranked := make([]RankedCategory, 0, len(categories))
+	for category, count := range categories {
+		ranked = append(ranked, RankedCategory{Category: category, Count: count})
+	}
+	slices.SortFunc(ranked, func(a, b RankedCategory) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Category, b.Category))
+	})
+
+	for i := range ranked {
+		if i > 0 && ranked[i].Count == ranked[i-1].Count {
+			ranked[i].Rank = ranked[i-1].Rank
+		} else {
+			ranked[i].Rank = i + 1
+		}
+	}
+
+	return ranked
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+
+	"geods-poi-api/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTopCategoriesFollowsSummary(t *testing.T) {
+	db := newTestDB(t)
+	// With a zero hard TTL every request recomputes the summary.
+	summary, err := Summarize(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries := NewSummaryCache(db, summary, 0, 0)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/top", TopCategories(summaries))
+
+	var before TopCategoriesResponse
+	getJSON(t, r, "/top?limit=1", &before)
+	if len(before.Categories) != 1 || before.Categories[0].Category != "bar" || before.Categories[0].Count != 2 {
+		t.Fatalf("expected bar to be the top category, got %+v", before.Categories)
+	}
+
+	cafe := "cafe"
+	fixtures := make([]testutil.Fixture, 3)
+	for i := range fixtures {
+		fixtures[i] = testutil.Fixture{Id: cafe, MainCategory: &cafe, Source: "test", SourceRecordId: cafe, Lat: 54.97, Long: -1.61}
+	}
+	if err := testutil.Seed(db, fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	var after TopCategoriesResponse
+	getJSON(t, r, "/top?limit=1", &after)
+	if len(after.Categories) != 1 || after.Categories[0].Category != "cafe" || after.Categories[0].Count != 4 {
+		t.Errorf("expected cafe to overtake bar once the summary was refreshed, got %+v", after.Categories)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/andybalholm/brotli"
@@ -73,61 +76,183 @@ const UNSPLASH_API_URL = "https://api.unsplash.com/search/photos"
 
 var httpClient = &http.Client{}
 
-func Image(cache *memoize.Memoizer) func(c *gin.Context) {
+// orientations are the Unsplash photo orientations a client may ask for.
+var orientations = []string{"landscape", "portrait", "squarish"}
+
+func parseOrientation(value string) (string, error) {
+	if value == "" {
+		return "landscape", nil
+	}
+	if !slices.Contains(orientations, value) {
+		return "", fmt.Errorf("orientation must be one of: %s", strings.Join(orientations, ", "))
+	}
+	return value, nil
+}
+
+// photoSizes maps the size parameter to the photo URL of that size.
+var photoSizes = map[string]func(URLs) string{
+	"thumb":   func(u URLs) string { return u.Thumb },
+	"small":   func(u URLs) string { return u.Small },
+	"regular": func(u URLs) string { return u.Regular },
+}
+
+// parseSize returns the URL of the requested size from a photo's URLs,
+// defaulting to small.
+func parseSize(value string) (func(URLs) string, error) {
+	if value == "" {
+		value = "small"
+	}
+	size, ok := photoSizes[value]
+	if !ok {
+		return nil, fmt.Errorf("size must be one of: thumb, small, regular")
+	}
+	return size, nil
+}
+
+// LoadImageQueries reads a JSON object mapping categories to the search terms
+// used when querying Unsplash. A missing file yields no overrides.
+func LoadImageQueries(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("No image query overrides found at %s", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading image queries: %w", err)
+	}
+
+	var queries map[string]string
+	if err := json.Unmarshal(contents, &queries); err != nil {
+		return nil, fmt.Errorf("error parsing image queries: %w", err)
+	}
+
+	log.Printf("Loaded %d image query overrides from %s", len(queries), path)
+	return queries, nil
+}
+
+// Image returns the Unsplash photo for a category, in the orientation and
+// size requested (by default, a small landscape photo). When Unsplash has no
+// match, fallbackURL (or the category's marker icon, if unset) is returned
+// instead, flagged with "fallback": true.
+func Image(cache *memoize.Memoizer, queries map[string]string, fallbackURL string) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		category := c.Param("category")
-		if category == "" {
-			c.JSON(400, gin.H{"error": "category is required"})
+		size, err := parseSize(c.Query("size"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		if _, exists := icons[category]; !exists {
-			c.JSON(404, gin.H{"error": "category not found"})
+		photo, ok := lookupPhoto(c, cache, queries)
+		if !ok {
 			return
 		}
 
-		resp, err, _ := memoize.Call(cache, fmt.Sprintf("image/%s", category), func() (*Response, error) {
-			log.Printf("Fetching image for category: %s", category)
-			return fetch(c.Request.Context(), category)
-		})
+		c.JSON(200, imageResponse(photo, size, c.Param("category"), fallbackURL))
+	}
+}
+
+// imageResponse describes the photo for a category, or the fallback image if
+// photo is nil.
+func imageResponse(photo *Photo, size func(URLs) string, category string, fallbackURL string) gin.H {
+	if photo == nil {
+		src := fallbackURL
+		if src == "" {
+			src = markerURL(category)
+		}
+
+		return gin.H{
+			"src":      src,
+			"alt":      category,
+			"fallback": true,
+		}
+	}
+
+	return gin.H{
+		"src": size(photo.URLs),
+		"alt": photo.AltDescription,
+		"attribution": gin.H{
+			"name": photo.User.Name,
+			"link": photo.User.Links.HTML,
+		},
+		"download_location": photo.Links.DownloadLocation,
+	}
+}
 
+func markerURL(category string) string {
+	return "/v1/geods-poi/marker/" + url.PathEscape(category)
+}
+
+// TrackImage notifies Unsplash that the image for a category has been used,
+// as required by the Unsplash API guidelines.
+func TrackImage(cache *memoize.Memoizer, queries map[string]string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		photo, ok := lookupPhoto(c, cache, queries)
+		if !ok {
+			return
+		}
+		if photo == nil {
+			c.JSON(404, gin.H{"error": "no image found for category"})
+			return
+		}
+
+		if _, err := get(c.Request.Context(), photo.Links.DownloadLocation, nil); err != nil {
+			log.Printf("Error tracking image download: %v", err)
+			c.JSON(502, gin.H{"error": "failed to track image download"})
+			return
+		}
+
+		c.Status(204)
+	}
+}
+
+type imageData struct {
+	contentType string
+	body        []byte
+}
+
+// RawImage proxies the Unsplash image for a category, caching the bytes
+// server-side so clients don't each hit Unsplash directly.
+func RawImage(cache *memoize.Memoizer, queries map[string]string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		size, err := parseSize(c.Query("size"))
 		if err != nil {
-			log.Printf("Error fetching image: %v", err)
-			c.JSON(500, gin.H{"error": "failed to fetch image"})
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		photo, ok := lookupPhoto(c, cache, queries)
+		if !ok {
 			return
 		}
-		if len(resp.Results) == 0 {
+		if photo == nil {
 			c.JSON(404, gin.H{"error": "no image found for category"})
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"src": resp.Results[0].URLs.Small,
-			"alt": resp.Results[0].AltDescription,
-			"attribution": gin.H{
-				"name": resp.Results[0].User.Name,
-				"link": resp.Results[0].User.Links.HTML,
-			},
+		imageURL := size(photo.URLs)
+		img, err, _ := memoize.Call(cache, fmt.Sprintf("image-raw/%s", imageURL), func() (*imageData, error) {
+			log.Printf("Proxying image for category: %s", c.Param("category"))
+			return download(c.Request.Context(), imageURL)
 		})
+		if err != nil {
+			log.Printf("Error proxying image: %v", err)
+			c.JSON(502, gin.H{"error": "failed to fetch image"})
+			return
+		}
+
+		c.Data(200, img.contentType, img.body)
 	}
 }
 
-func fetch(ctx context.Context, category string) (*Response, error) {
-
-	req, err := http.NewRequestWithContext(ctx, "GET", UNSPLASH_API_URL, nil)
+func download(ctx context.Context, imageURL string) (*imageData, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
-
-	q := req.URL.Query()
-	q.Add("query", category)
-	q.Add("per_page", "1")
-	q.Add("orientation", "landscape")
-	q.Add("order_by", "relevant")
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set("Authorization", "Client-ID "+os.Getenv("UNSPLASH_ACCESS_KEY"))
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Encoding", "gzip,br,deflate")
 	req.Header.Set("User-Agent", "https://github.com/rm-hull/geods-poi-api")
 
 	resp, err := httpClient.Do(req)
@@ -140,6 +265,142 @@ func fetch(ctx context.Context, category string) (*Response, error) {
 		}
 	}()
 
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response (%s)", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	return &imageData{contentType: contentType, body: body}, nil
+}
+
+// lookupPhoto resolves the (cached) Unsplash photo for the category in the
+// request path, writing an error response and returning false on failure.
+// A nil photo means Unsplash returned no results.
+func lookupPhoto(c *gin.Context, cache *memoize.Memoizer, queries map[string]string) (*Photo, bool) {
+	category := c.Param("category")
+	if category == "" {
+		c.JSON(400, gin.H{"error": "category is required"})
+		return nil, false
+	}
+
+	orientation, err := parseOrientation(c.Query("orientation"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if _, exists := currentIcons()[category]; !exists {
+		c.JSON(404, gin.H{"error": "category not found"})
+		return nil, false
+	}
+
+	resp, err := searchPhotos(c.Request.Context(), cache, queries, category, orientation)
+	if err != nil {
+		log.Printf("Error fetching image: %v", err)
+		c.JSON(500, gin.H{"error": "failed to fetch image"})
+		return nil, false
+	}
+	if len(resp.Results) == 0 {
+		return nil, true
+	}
+
+	return &resp.Results[0], true
+}
+
+// searchPhotos returns the (cached) Unsplash search results for a category.
+func searchPhotos(ctx context.Context, cache *memoize.Memoizer, queries map[string]string, category string, orientation string) (*Response, error) {
+	resp, err, _ := memoize.Call(cache, fmt.Sprintf("image/%s/%s", category, orientation), func() (*Response, error) {
+		query := category
+		if override, ok := queries[category]; ok && override != "" {
+			query = override
+		}
+		log.Printf("Fetching image for category: %s (query: %s, orientation: %s)", category, query, orientation)
+		return fetch(ctx, query, orientation)
+	})
+	return resp, err
+}
+
+func fetch(ctx context.Context, query string, orientation string) (*Response, error) {
+	params := url.Values{}
+	params.Add("query", query)
+	params.Add("per_page", "1")
+	params.Add("orientation", orientation)
+	params.Add("order_by", "relevant")
+
+	body, err := get(ctx, UNSPLASH_API_URL, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return &response, nil
+}
+
+// get performs an authenticated request against the Unsplash API, merging
+// params into any query string already present on rawURL. If a key is rate
+// limited, the request is retried with the next, until every key is tried.
+// The outcome is recorded for the status endpoint.
+func get(ctx context.Context, rawURL string, params url.Values) (body []byte, err error) {
+	defer func() { unsplashCalls.record(err) }()
+	keys := accessKeys()
+
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		q := req.URL.Query()
+		for key, values := range params {
+			for _, value := range values {
+				q.Add(key, value)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+
+		key := keys.pick()
+		req.Header.Set("Authorization", "Client-ID "+key.value)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Encoding", "gzip,br,deflate")
+		req.Header.Set("User-Agent", "https://github.com/rm-hull/geods-poi-api")
+
+		resp, err = httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error making request: %w", err)
+		}
+		keys.update(key, resp)
+
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= keys.size() {
+			break
+		}
+
+		log.Printf("Unsplash key rate limited, retrying with another (attempt %d of %d)", attempt, keys.size())
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}()
+
 	var reader io.Reader = resp.Body
 	switch resp.Header.Get("Content-Encoding") {
 	case "br":
@@ -163,7 +424,7 @@ func fetch(ctx context.Context, category string) (*Response, error) {
 		}()
 	}
 
-	body, err := io.ReadAll(reader)
+	body, err = io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
@@ -172,11 +433,5 @@ func fetch(ctx context.Context, category string) (*Response, error) {
 		return nil, fmt.Errorf("bad response (%s): %s", resp.Status, body)
 	}
 
-	var response Response
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
-	}
-
-	return &response, nil
+	return body, nil
 }
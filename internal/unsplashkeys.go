@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow is how often Unsplash resets a key's request budget.
+const rateLimitWindow = time.Hour
+
+type accessKey struct {
+	value string
+	// remaining is the budget last reported by Unsplash, or -1 if unknown.
+	remaining int
+	updated   time.Time
+}
+
+// available reports whether the key is believed to have budget left.
+func (k *accessKey) available() bool {
+	return k.remaining != 0 || time.Since(k.updated) > rateLimitWindow
+}
+
+// keyRing rotates round-robin between Unsplash access keys, skipping those
+// whose rate limit budget is spent, to multiply the effective quota.
+type keyRing struct {
+	mu   sync.Mutex
+	keys []*accessKey
+	next int
+}
+
+var unsplashKeys keyRing
+var loadKeys sync.Once
+
+// accessKeys returns the ring of keys from UNSPLASH_ACCESS_KEY, which may be
+// a comma-separated list. It's read on first use, after .env has loaded.
+func accessKeys() *keyRing {
+	loadKeys.Do(func() {
+		for key := range strings.SplitSeq(os.Getenv("UNSPLASH_ACCESS_KEY"), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				unsplashKeys.keys = append(unsplashKeys.keys, &accessKey{value: key, remaining: -1})
+			}
+		}
+		if len(unsplashKeys.keys) == 0 {
+			unsplashKeys.keys = []*accessKey{{remaining: -1}}
+		}
+	})
+	return &unsplashKeys
+}
+
+func (r *keyRing) size() int {
+	return len(r.keys)
+}
+
+// configured reports whether any access key was supplied.
+func (r *keyRing) configured() bool {
+	return r.keys[0].value != ""
+}
+
+// hasBudget reports whether any key is believed to have budget left.
+func (r *keyRing) hasBudget() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.ContainsFunc(r.keys, (*accessKey).available)
+}
+
+// pick returns the next key with budget, or simply the next key if they're
+// all spent.
+func (r *keyRing) pick() *accessKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		key := r.keys[(r.next+i)%len(r.keys)]
+		if key.available() {
+			r.next = (r.next + i + 1) % len(r.keys)
+			return key
+		}
+	}
+
+	key := r.keys[r.next]
+	r.next = (r.next + 1) % len(r.keys)
+	return key
+}
+
+// update records the budget Unsplash reports for key in resp.
+func (r *keyRing) update(key *accessKey, resp *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		key.remaining = 0
+		key.updated = time.Now()
+		return
+	}
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Remaining")); err == nil {
+		key.remaining = remaining
+		key.updated = time.Now()
+	}
+}
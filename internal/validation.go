@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamError describes a single invalid request parameter.
+type ParamError struct {
+	Param string `json:"param"`
+	Error string `json:"error"`
+}
+
+// paramErrors accumulates validation failures so that every bad parameter
+// can be reported in one response, rather than just the first.
+type paramErrors []ParamError
+
+// add records err against param, if it is non-nil.
+func (p *paramErrors) add(param string, err error) {
+	if err != nil {
+		*p = append(*p, ParamError{Param: param, Error: err.Error()})
+	}
+}
+
+// respond writes a 400 listing every failure in details; error joins their
+// messages, for clients that only read that.
+func (p paramErrors) respond(c *gin.Context) {
+	messages := make([]string, 0, len(p))
+	for _, e := range p {
+		messages = append(messages, e.Error)
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   strings.Join(messages, "; "),
+		"details": p,
+	})
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultValuesLimit = 100
+	maxValuesLimit     = 1000
+)
+
+// valueFields are the POI fields whose distinct values can be listed, for
+// populating filter pickers.
+var valueFields = []string{"locality", "region", "country", "source"}
+
+type FieldValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type ValuesResponse struct {
+	Field  string       `json:"field"`
+	Values []FieldValue `json:"values"`
+	// Total is the number of distinct values matching q, across all pages.
+	Total int `json:"total"`
+}
+
+// RefDataValues lists the distinct values of a field, alphabetically and
+// with their POI counts, a page at a time. With q, only values starting with
+// it are listed.
+func RefDataValues(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var errs paramErrors
+		field := c.Query("field")
+		if !slices.Contains(valueFields, field) {
+			errs.add("field", fmt.Errorf("field must be one of: %s", strings.Join(valueFields, ", ")))
+		}
+		q := strings.TrimSpace(c.Query("q"))
+		limit, err := parseLimit("limit", c.Query("limit"))
+		errs.add("limit", err)
+		offset, err := parseOffset(c.Query("offset"))
+		errs.add("offset", err)
+		if len(errs) > 0 {
+			errs.respond(c)
+			return
+		}
+
+		if limit == 0 {
+			limit = defaultValuesLimit
+		}
+		limit = min(limit, maxValuesLimit)
+
+		col := column(field)
+		where := col + " IS NOT NULL AND " + col + " != ''"
+		var args []any
+		if q != "" {
+			clause, arg := likePrefix(col, q)
+			where += " AND " + clause
+			args = append(args, arg)
+		}
+
+		ctx := c.Request.Context()
+		var total int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT "+col+") FROM "+table()+" WHERE "+where, args...).Scan(&total); err != nil {
+			log.Printf("error counting values: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx,
+			"SELECT "+col+", COUNT(*) FROM "+table()+" WHERE "+where+" GROUP BY "+col+" ORDER BY "+col+" LIMIT ? OFFSET ?",
+			append(args, limit, offset)...,
+		)
+		if err != nil {
+			log.Printf("error querying database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				log.Printf("error closing rows: %v", err)
+			}
+		}()
+
+		values := make([]FieldValue, 0)
+		for rows.Next() {
+			var v FieldValue
+			if err := rows.Scan(&v.Value, &v.Count); err != nil {
+				log.Printf("error scanning row: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+				return
+			}
+			values = append(values, v)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("error during rows iteration: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ValuesResponse{Field: field, Values: values, Total: total})
+	}
+}
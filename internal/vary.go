@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const varyKey = "vary"
+
+// PreserveVary ensures Vary values declared by handlers (via addVary) survive
+// to the response. It must be registered before the compression middleware,
+// which otherwise overwrites Vary with just "Accept-Encoding".
+func PreserveVary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &varyWriter{ResponseWriter: c.Writer, c: c}
+		c.Next()
+	}
+}
+
+// addVary records request headers the response varies on.
+func addVary(c *gin.Context, headers ...string) {
+	c.Set(varyKey, append(c.GetStringSlice(varyKey), headers...))
+}
+
+type varyWriter struct {
+	gin.ResponseWriter
+	c *gin.Context
+}
+
+func (w *varyWriter) mergeVary() {
+	if w.Written() {
+		return
+	}
+
+	extra := w.c.GetStringSlice(varyKey)
+	if len(extra) == 0 {
+		return
+	}
+
+	seen := make(map[string]struct{})
+	var merged []string
+	for _, value := range append(w.Header().Values("Vary"), extra...) {
+		for header := range strings.SplitSeq(value, ",") {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if _, exists := seen[header]; header != "" && !exists {
+				seen[header] = struct{}{}
+				merged = append(merged, header)
+			}
+		}
+	}
+
+	w.Header().Set("Vary", strings.Join(merged, ", "))
+}
+
+func (w *varyWriter) WriteHeader(code int) {
+	w.mergeVary()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *varyWriter) WriteHeaderNow() {
+	w.mergeVary()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *varyWriter) Write(data []byte) (int, error) {
+	w.mergeVary()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *varyWriter) WriteString(s string) (int, error) {
+	w.mergeVary()
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aurowora/compress"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPreserveVaryThroughCompression(t *testing.T) {
+	db := newTestDB(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(PreserveVary(), compress.Compress())
+	r.GET("/search", Search(db, SearchConfig{}))
+	r.GET("/plain", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("plain ", 1000))
+	})
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		wantVary       []string
+	}{
+		{"/search?bbox=" + fixturesBBox, "gzip", []string{"Accept-Encoding", "Accept"}},
+		{"/search?bbox=" + fixturesBBox, "", []string{"Accept-Encoding", "Accept"}},
+		{"/plain", "gzip", []string{"Accept-Encoding"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path+" "+tc.acceptEncoding, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tc.acceptEncoding {
+				t.Errorf("Content-Encoding %q, want %q", got, tc.acceptEncoding)
+			}
+
+			var vary []string
+			for _, value := range w.Header().Values("Vary") {
+				for header := range strings.SplitSeq(value, ",") {
+					vary = append(vary, strings.TrimSpace(header))
+				}
+			}
+			slices.Sort(vary)
+			slices.Sort(tc.wantVary)
+			if !slices.Equal(vary, tc.wantVary) {
+				t.Errorf("Vary %v, want %v", vary, tc.wantVary)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxZoom is the deepest zoom level of common web map tile schemes.
+const maxZoom = 22
+
+// MinZooms maps main categories onto the lowest map zoom level at which
+// their POIs are shown; categories not listed are shown at every zoom.
+type MinZooms map[string]int
+
+// LoadMinZooms reads a JSON object of minimum zoom levels by category, such
+// as {"bench": 16}. Without a file every category is shown at every zoom.
+func LoadMinZooms(path string) (MinZooms, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading minimum zooms: %w", err)
+	}
+
+	var minZooms MinZooms
+	if err := json.Unmarshal(contents, &minZooms); err != nil {
+		return nil, fmt.Errorf("error parsing minimum zooms: %w", err)
+	}
+	for category, zoom := range minZooms {
+		if zoom < 0 || zoom > maxZoom {
+			return nil, fmt.Errorf("invalid minimum zoom %d for %s: must be between 0 and %d", zoom, category, maxZoom)
+		}
+	}
+
+	log.Printf("Loaded minimum zooms for %d categories from %s", len(minZooms), path)
+	return minZooms, nil
+}
+
+// suppressedAt returns, sorted, the categories hidden at a zoom level.
+func (m MinZooms) suppressedAt(zoom int) []string {
+	var suppressed []string
+	for _, category := range slices.Sorted(maps.Keys(m)) {
+		if zoom < m[category] {
+			suppressed = append(suppressed, category)
+		}
+	}
+	return suppressed
+}
+
+// suppressedClause is the WHERE predicate excluding POIs whose main category
+// is one of suppressed. Filtering in SQL keeps paging and counts exact.
+func suppressedClause(suppressed []string) (string, []any) {
+	args := make([]any, len(suppressed))
+	for i, category := range suppressed {
+		args[i] = category
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(suppressed)), ", ")
+	return "(" + column("main_category") + " IS NULL OR " + column("main_category") + " NOT IN (" + placeholders + "))", args
+}
+
+// parseZoom parses an optional map zoom level; -1 means none was given.
+func parseZoom(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+
+	zoom, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || zoom < 0 || zoom > maxZoom {
+		return 0, fmt.Errorf("invalid zoom value '%s': must be an integer between 0 and %d", value, maxZoom)
+	}
+
+	return zoom, nil
+}
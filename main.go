@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"geods-poi-api/data"
 	"geods-poi-api/internal"
 	"log"
 	"os"
@@ -14,7 +15,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/kofalt/go-memoize"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
 
 	healthcheck "github.com/tavsec/gin-healthcheck"
@@ -23,10 +23,54 @@ import (
 	cachecontrol "go.eigsys.de/gin-cachecontrol/v2"
 )
 
+type serverConfig struct {
+	dbPath           string
+	columnMapping    string
+	pragmas          []string
+	dbRetries        int
+	dbRetryInterval  time.Duration
+	queryTimeout     time.Duration
+	busyTimeout      time.Duration
+	busyRetries      int
+	markersDir       string
+	markerMappings   string
+	markerAnchors    string
+	imageQueriesPath string
+	fallbackImageURL string
+	prefetchImages   int
+	prefetchWorkers  int
+	labelsPath       string
+	attributionPath  string
+	taxonomyPath     string
+	minZoomsPath     string
+	relevancePath    string
+	lsoaNamesPath    string
+	tlsCert          string
+	tlsKey           string
+	http2            bool
+	dev              bool
+	noCompress       []string
+	trustedProxies   []string
+	strictParams     bool
+	rtree            bool
+	searchMeta       bool
+	logFiltered      bool
+	defaultBBox      string
+	precision        int
+	maxCategories    int
+	categoryDelim    string
+	flushRows        int
+	maxResponseBytes int
+	minAutocomplete  int
+	refDataSoftTTL   time.Duration
+	refDataHardTTL   time.Duration
+	exportTTL        time.Duration
+	port             int
+}
+
 func main() {
 	var err error
-	var dbPath string
-	var port int
+	var cfg serverConfig
 
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
@@ -37,40 +81,230 @@ func main() {
 		Use:   "http",
 		Short: "GeoDS-POI API server",
 		Run: func(cmd *cobra.Command, args []string) {
-			server(dbPath, port)
+			server(&cfg)
 		},
 	}
 
-	rootCmd.Flags().StringVar(&dbPath, "db", "./data/poi_uk.gpkg", "Path to GeoPackage SQLite database")
-	rootCmd.Flags().IntVar(&port, "port", 8080, "Port to run HTTP server on")
+	rootCmd.PersistentFlags().StringVar(&cfg.dbPath, "db", "./data/poi_uk.gpkg", "Path to GeoPackage SQLite database, or :memory: to serve non-persistent test fixtures")
+	rootCmd.PersistentFlags().IntVar(&cfg.dbRetries, "db-retries", 5, "Number of times to retry opening the database before giving up")
+	rootCmd.PersistentFlags().DurationVar(&cfg.dbRetryInterval, "db-retry-interval", time.Second, "Initial delay between database open retries, doubling after each attempt")
+	rootCmd.PersistentFlags().DurationVar(&cfg.queryTimeout, "query-timeout", 10*time.Second, "Maximum time a search query may run (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&cfg.busyTimeout, "busy-timeout", 5*time.Second, "How long SQLite waits for a lock held by another connection before reporting the database busy")
+	rootCmd.PersistentFlags().IntVar(&cfg.busyRetries, "busy-retries", 3, "Times a query that finds the database busy is retried, with backoff, before failing")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.pragmas, "pragma", []string{"cache_size=-65536", "mmap_size=268435456", "temp_store=memory"}, "SQLite PRAGMA applied to each connection as name=value; one of cache_size, mmap_size, temp_store or journal_mode")
+	rootCmd.PersistentFlags().StringVar(&cfg.columnMapping, "column-mapping", "", "Optional JSON file mapping the POI table and column names onto a non-standard schema")
+	rootCmd.Flags().StringVar(&cfg.markersDir, "markers-dir", "", "Optional directory of marker icons that override the embedded set")
+	rootCmd.Flags().StringVar(&cfg.markerAnchors, "marker-anchors", "", "Optional JSON file of marker icon anchor points, as {\"icon.png\": [x, y]}, overriding the bottom centre")
+	rootCmd.Flags().StringVar(&cfg.markerMappings, "marker-mappings", "", "Optional JSON file of category to marker icon mappings overriding the embedded set; reloadable at runtime")
+	rootCmd.Flags().StringVar(&cfg.imageQueriesPath, "image-queries", "./data/image-queries.json", "Path to JSON file of per-category Unsplash search terms")
+	rootCmd.Flags().StringVar(&cfg.fallbackImageURL, "fallback-image", "", "Image URL returned when Unsplash has no match (defaults to the category marker)")
+	rootCmd.Flags().IntVar(&cfg.prefetchImages, "prefetch-images", 0, "Number of the most common categories to prefetch Unsplash images for at startup")
+	rootCmd.Flags().IntVar(&cfg.prefetchWorkers, "prefetch-concurrency", 2, "Maximum concurrent Unsplash requests while prefetching images")
+	rootCmd.Flags().StringVar(&cfg.labelsPath, "labels", "./data/category-labels.json", "Path to JSON file of localised category labels")
+	rootCmd.Flags().StringVar(&cfg.attributionPath, "source-attribution", "./data/source-attribution.json", "Path to JSON file mapping data sources to their required attribution")
+	rootCmd.Flags().StringVar(&cfg.minZoomsPath, "min-zoom", "", "Optional JSON file of the minimum map zoom at which each category is shown, as {\"bench\": 16}, for search ?zoom=")
+	rootCmd.Flags().StringVar(&cfg.relevancePath, "relevance-weights", "", "Optional JSON file of search ?sort=relevance weights, as {\"name\": 0.5, \"distance\": 0.5, \"distance_scale\": 1000, \"categories\": {}}")
+	rootCmd.Flags().StringVar(&cfg.taxonomyPath, "taxonomy", "./data/simple-taxonomy.json", "Path to JSON file collapsing categories into the simple display taxonomy")
+	rootCmd.Flags().StringVar(&cfg.lsoaNamesPath, "lsoa-names", "./data/lsoa-names.csv", "Path to CSV file of LSOA 2021 codes (LSOA21CD) and names (LSOA21NM)")
+	rootCmd.Flags().StringVar(&cfg.tlsCert, "tls-cert", "", "Path to TLS certificate; serves HTTPS (with HTTP/2) when set with --tls-key")
+	rootCmd.Flags().StringVar(&cfg.tlsKey, "tls-key", "", "Path to TLS private key")
+	rootCmd.Flags().BoolVar(&cfg.http2, "http2", false, "Enable cleartext HTTP/2 (h2c), e.g. behind a TLS-terminating proxy")
+	rootCmd.Flags().BoolVar(&cfg.dev, "dev", false, "Enable developer diagnostics such as search ?explain=true (do not use in production)")
+	rootCmd.Flags().IntVar(&cfg.precision, "coordinate-precision", -1, "Decimal places for coordinates in JSON responses (-1 for the shortest exact value)")
+	rootCmd.Flags().IntVar(&cfg.maxCategories, "max-categories", 100, "Maximum number of categories a single request may filter by")
+	rootCmd.Flags().StringVar(&cfg.categoryDelim, "category-delimiter", "|", "Separator between the categories in alternate_category")
+	rootCmd.Flags().IntVar(&cfg.maxResponseBytes, "max-response-bytes", 0, "Truncate search results once their JSON exceeds this many bytes, flagging the response as truncated (0 for no limit)")
+	rootCmd.Flags().IntVar(&cfg.flushRows, "search-flush-rows", 500, "Stream search results, flushing every N POIs so clients can render progressively (0 to buffer the whole response)")
+	rootCmd.Flags().IntVar(&cfg.minAutocomplete, "autocomplete-min-length", 3, "Shortest name prefix autocomplete will search for")
+	rootCmd.Flags().BoolVar(&cfg.searchMeta, "search-attribution", false, "Include the full dataset attribution from ref-data in every search response, under meta.attribution")
+	rootCmd.Flags().BoolVar(&cfg.logFiltered, "log-filtered", false, "Log how many rows each search fetched and how many its category, per-category and dedupe filters dropped")
+	rootCmd.Flags().BoolVar(&cfg.rtree, "rtree", false, "Use the GeoPackage R-tree index for bbox queries, unless it is stale")
+	rootCmd.Flags().BoolVar(&cfg.strictParams, "strict-params", false, "Reject requests with query parameters the endpoint doesn't accept")
+	rootCmd.Flags().StringSliceVar(&cfg.trustedProxies, "trusted-proxies", nil, "IPs or CIDRs of reverse proxies whose X-Forwarded-For gives the client IP (default none)")
+	rootCmd.Flags().StringSliceVar(&cfg.noCompress, "no-compress", nil, "Additional route patterns to serve uncompressed, e.g. /v1/geods-poi/markers/manifest.ndjson")
+	rootCmd.Flags().DurationVar(&cfg.refDataSoftTTL, "ref-data-soft-ttl", time.Hour, "Age after which ref-data is recomputed in the background while the cached copy is served")
+	rootCmd.Flags().DurationVar(&cfg.refDataHardTTL, "ref-data-hard-ttl", 24*time.Hour, "Age after which ref-data requests wait for a fresh recompute")
+	rootCmd.Flags().DurationVar(&cfg.exportTTL, "export-ttl", time.Hour, "How long a finished export is kept for download before its file is removed")
+	rootCmd.Flags().StringVar(&cfg.defaultBBox, "default-bbox", "", "Bbox searched when a request omits one: \"extent\" or left,bottom,right,top (default: bbox required)")
+	rootCmd.Flags().IntVar(&cfg.port, "port", 8080, "Port to run HTTP server on")
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "Validate the GeoPackage database without starting the server",
+		Run: func(cmd *cobra.Command, args []string) {
+			check(&cfg)
+		},
+	})
 
 	if err = rootCmd.Execute(); err != nil {
 		panic(err)
 	}
 }
 
-func server(dbPath string, port int) {
+// memoryDB is the --db value that serves the test fixtures from memory.
+const memoryDB = ":memory:"
+
+// openDB connects to the database, retrying with exponential backoff so that
+// a volume which mounts slightly late doesn't crash-loop the container.
+func openDB(cfg *serverConfig) *sql.DB {
+	pragmas, err := internal.ParsePragmas(cfg.pragmas)
+	if err != nil {
+		log.Fatalf("invalid --pragma: %v", err)
+	}
+	if cfg.busyTimeout < 0 || cfg.busyRetries < 0 {
+		log.Fatalf("--busy-timeout and --busy-retries must not be negative")
+	}
+	internal.RegisterSQLiteDriver(pragmas, cfg.busyRetries)
+
+	if cfg.dbPath == memoryDB {
+		db, err := openMemoryDB(cfg.busyTimeout)
+		if err != nil {
+			log.Fatalf("failed to create in-memory database: %v", err)
+		}
+		internal.LogPragmas(db)
+		return db
+	}
+
+	delay := cfg.dbRetryInterval
+	for attempt := 1; ; attempt++ {
+		db, err := connect(cfg.dbPath, cfg.busyTimeout)
+		if err == nil {
+			log.Printf("connected to database: %s\n", cfg.dbPath)
+			internal.LogPragmas(db)
+			return db
+		}
+
+		if attempt > cfg.dbRetries {
+			log.Fatalf("%v", err)
+		}
+
+		log.Printf("%v (attempt %d of %d, retrying in %s)", err, attempt, cfg.dbRetries+1, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func connect(dbPath string, busyTimeout time.Duration) (*sql.DB, error) {
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		log.Fatalf("database file does not exist: %s", dbPath)
+		return nil, fmt.Errorf("database file does not exist: %s", dbPath)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	dsn := fmt.Sprintf("%s?_busy_timeout=%d", dbPath, busyTimeout.Milliseconds())
+
+	db, err := sql.Open(internal.SQLiteDriver, dsn)
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	if err = db.Ping(); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("error closing database: %v", closeErr)
+		}
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
+func check(cfg *serverConfig) {
+	if err := internal.LoadColumnMapping(cfg.columnMapping); err != nil {
+		log.Fatalf("failed to load column mapping: %v", err)
+	}
+
+	if cfg.refDataHardTTL < cfg.refDataSoftTTL {
+		log.Fatalf("--ref-data-hard-ttl must not be less than --ref-data-soft-ttl")
+	}
+
+	if cfg.precision < -1 {
+		log.Fatalf("--coordinate-precision must be -1 or more")
+	}
+	internal.SetCoordinatePrecision(cfg.precision)
+
+	db := openDB(cfg)
 	defer func() {
 		if err := db.Close(); err != nil {
 			log.Printf("error closing database: %v", err)
 		}
 	}()
 
-	if err = db.Ping(); err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+	if err := internal.CheckDatabase(db); err != nil {
+		log.Fatalf("database check failed: %v", err)
+	}
+	log.Println("Database check passed")
+}
+
+func server(cfg *serverConfig) {
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		log.Fatalf("--tls-cert and --tls-key must be supplied together")
+	}
+
+	if err := internal.LoadColumnMapping(cfg.columnMapping); err != nil {
+		log.Fatalf("failed to load column mapping: %v", err)
+	}
+
+	if cfg.refDataHardTTL < cfg.refDataSoftTTL {
+		log.Fatalf("--ref-data-hard-ttl must not be less than --ref-data-soft-ttl")
+	}
+
+	if cfg.exportTTL <= 0 {
+		log.Fatalf("--export-ttl must be positive")
+	}
+
+	if cfg.precision < -1 {
+		log.Fatalf("--coordinate-precision must be -1 or more")
+	}
+	internal.SetCoordinatePrecision(cfg.precision)
+
+	if cfg.flushRows < 0 {
+		log.Fatalf("--search-flush-rows must not be negative")
+	}
+
+	if cfg.maxResponseBytes < 0 {
+		log.Fatalf("--max-response-bytes must not be negative")
+	}
+
+	if cfg.minAutocomplete < 1 {
+		log.Fatalf("--autocomplete-min-length must be at least 1")
+	}
+
+	if cfg.maxCategories < 1 {
+		log.Fatalf("--max-categories must be at least 1")
+	}
+	internal.SetMaxCategories(cfg.maxCategories)
+
+	if cfg.categoryDelim == "" {
+		log.Fatalf("--category-delimiter cannot be empty")
+	}
+	internal.SetCategoryDelimiter(cfg.categoryDelim)
+
+	db := openDB(cfg)
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("error closing database: %v", err)
+		}
+	}()
+
+	if err := internal.DetectOptionalColumns(db); err != nil {
+		log.Printf("WARNING: failed to detect optional columns: %v", err)
+	}
+
+	if cfg.rtree {
+		if err := internal.UseSpatialIndex(db); err != nil {
+			log.Printf("WARNING: failed to check spatial index: %v", err)
+		}
 	}
-	log.Printf("connected to database: %s\n", dbPath)
 
 	r := gin.New()
+	r.UseH2C = cfg.http2
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(internal.NoRoute)
+	r.NoMethod(internal.NoMethod)
+
+	// gin trusts X-Forwarded-For from anyone by default, letting any client
+	// spoof its IP, so only trust the proxies given.
+	if err := r.SetTrustedProxies(cfg.trustedProxies); err != nil {
+		log.Fatalf("invalid --trusted-proxies: %v", err)
+	}
 
 	prometheus := ginprom.New(
 		ginprom.Engine(r),
@@ -82,29 +316,156 @@ func server(dbPath string, port int) {
 		gin.Recovery(),
 		gin.LoggerWithWriter(gin.DefaultWriter, "/healthz", "/metrics"),
 		prometheus.Instrument(),
-		compress.Compress(),
+		internal.PreserveVary(),
+		compress.Compress(compress.WithExcludeFunc(internal.ExcludeFromCompression(cfg.noCompress))),
+		internal.PrettyJSON(),
 		cachecontrol.New(cachecontrol.CacheAssetsForeverPreset),
 		cors.Default(),
 	)
+	if cfg.strictParams {
+		r.Use(internal.StrictParams())
+	}
 
-	err = healthcheck.New(r, hc_config.DefaultConfig(), []checks.Check{
+	err := healthcheck.New(r, hc_config.DefaultConfig(), []checks.Check{
 		checks.SqlCheck{Sql: db},
 	})
 	if err != nil {
 		log.Fatalf("failed to initialize healthcheck: %v", err)
 	}
 
-	cache := memoize.NewMemoizer(10*24*time.Hour, 6*time.Hour)
+	markers, err := internal.MarkersFS(data.Markers, cfg.markersDir)
+	if err != nil {
+		log.Fatalf("failed to load markers: %v", err)
+	}
+
+	if err := internal.LoadMarkerMappings(cfg.markerMappings, markers); err != nil {
+		log.Fatalf("failed to load marker mappings: %v", err)
+	}
+
+	if err := internal.LoadIconSizes(markers, cfg.markerAnchors); err != nil {
+		log.Fatalf("failed to load marker icon sizes: %v", err)
+	}
+
+	imageQueries, err := internal.LoadImageQueries(cfg.imageQueriesPath)
+	if err != nil {
+		log.Fatalf("failed to load image queries: %v", err)
+	}
 
-	r.GET("/v1/geods-poi/ref-data", internal.RefData(db))
+	labels, err := internal.LoadLabels(cfg.labelsPath)
+	if err != nil {
+		log.Fatalf("failed to load category labels: %v", err)
+	}
+
+	sourceAttribution, err := internal.LoadSourceAttribution(cfg.attributionPath)
+	if err != nil {
+		log.Fatalf("failed to load source attribution: %v", err)
+	}
+
+	minZooms, err := internal.LoadMinZooms(cfg.minZoomsPath)
+	if err != nil {
+		log.Fatalf("failed to load minimum zooms: %v", err)
+	}
+
+	relevance, err := internal.LoadRelevanceWeights(cfg.relevancePath)
+	if err != nil {
+		log.Fatalf("failed to load relevance weights: %v", err)
+	}
+
+	taxonomy, err := internal.LoadTaxonomy(cfg.taxonomyPath)
+	if err != nil {
+		log.Fatalf("failed to load category taxonomy: %v", err)
+	}
+
+	lsoaNames, err := internal.LoadLSOANames(cfg.lsoaNamesPath)
+	if err != nil {
+		log.Fatalf("failed to load LSOA names: %v", err)
+	}
+
+	cache := internal.TrackCache("images", memoize.NewMemoizer(10*24*time.Hour, 6*time.Hour))
+
+	// A summary that failed is still served, degraded, until a refresh works.
+	summary, _ := internal.Summarize(db)
+	summaries := internal.NewSummaryCache(db, summary, cfg.refDataSoftTTL, cfg.refDataHardTTL)
+	internal.PrefetchImages(cache, imageQueries, summary, cfg.prefetchImages, cfg.prefetchWorkers)
+
+	defaultBBox, err := internal.ParseDefaultBBox(cfg.defaultBBox, summary)
+	if err != nil {
+		log.Fatalf("invalid --default-bbox: %v", err)
+	}
+
+	r.GET("/v1/geods-poi/status", internal.Status(db, summaries))
+	r.GET("/v1/geods-poi/ref-data", internal.RefData(summaries, labels, sourceAttribution, taxonomy))
+	r.GET("/v1/geods-poi/ref-data/top", internal.TopCategories(summaries))
+	r.GET("/v1/geods-poi/ref-data/values", internal.RefDataValues(db))
+	r.GET("/v1/geods-poi/ref-data/schema", internal.Schema(db))
+	r.GET("/v1/geods-poi/ref-data/gpkg", internal.GeoPackageContents(db))
+	r.GET("/v1/geods-poi/ref-data/crosstab", internal.Crosstab(db, cfg.refDataHardTTL))
 	r.GET("/v1/geods-poi/category-groups", internal.CategoryGroups)
-	r.GET("/v1/geods-poi/search", internal.Search(db))
-	r.GET("/v1/geods-poi/marker/shadow", internal.Shadow)
-	r.GET("/v1/geods-poi/marker/:category", internal.Marker)
-	r.GET("/v1/geods-poi/image/:category", internal.Image(cache))
-
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Starting HTTP API Server on port %d...", port)
-	err = r.Run(addr)
-	log.Fatalf("HTTP API Server failed to start on port %d: %v", port, err)
+	var searchAttribution func() []string
+	if cfg.searchMeta {
+		searchAttribution = internal.DatasetAttribution(summaries, sourceAttribution)
+	}
+	search := internal.Search(db, internal.SearchConfig{
+		Dev:              cfg.dev,
+		Taxonomy:         taxonomy,
+		MinZooms:         minZooms,
+		Relevance:        relevance,
+		QueryTimeout:     cfg.queryTimeout,
+		DefaultBBox:      defaultBBox,
+		FlushRows:        cfg.flushRows,
+		MaxResponseBytes: cfg.maxResponseBytes,
+		Attribution:      searchAttribution,
+		LSOANames:        lsoaNames,
+		CategoryCounts: func() map[string]int {
+			summary, _ := summaries.Get()
+			return summary.Categories
+		},
+		LogFiltered: cfg.logFiltered,
+	})
+	r.GET("/v1/geods-poi/search", search)
+	r.POST("/v1/geods-poi/search", internal.SearchPost(search))
+	r.GET("/v1/geods-poi/map-init", internal.MapInit(search, summaries))
+	r.POST("/v1/geods-poi/search/corridor", internal.SearchCorridor(db))
+	r.GET("/v1/geods-poi/nearest", internal.Nearest(db))
+	r.GET("/v1/geods-poi/overview", internal.Overview(db))
+	r.GET("/v1/geods-poi/estimate", internal.Estimate(db, cfg.refDataHardTTL))
+
+	streams := internal.NewSearchStreams(db)
+	r.GET("/v1/geods-poi/search/stream", streams.Stream)
+	r.POST("/v1/geods-poi/search/stream/:session", streams.Update)
+
+	exports := internal.NewExports(db, cfg.exportTTL)
+	r.POST("/v1/geods-poi/export", exports.Start)
+	r.GET("/v1/geods-poi/export/:id", exports.Get)
+	r.GET("/v1/geods-poi/autocomplete", internal.Autocomplete(db, cfg.minAutocomplete))
+	r.GET("/v1/geods-poi/changes", internal.Changes(db))
+	r.GET("/v1/geods-poi/extent", internal.Extent(db))
+	r.GET("/v1/geods-poi/coverage", internal.Coverage(db))
+	r.GET("/v1/geods-poi/h3/cover", internal.H3Cover())
+	r.GET("/v1/geods-poi/h3/occupied", internal.H3OccupiedCells(db))
+	r.GET("/v1/geods-poi/marker/shadow", internal.Shadow(markers))
+	r.GET("/v1/geods-poi/marker/:category", internal.Marker(markers))
+	r.GET("/v1/geods-poi/marker/:category/meta", internal.MarkerMeta(markers))
+	r.GET("/v1/geods-poi/markers/manifest.ndjson", internal.MarkersManifest(markers))
+	r.GET("/v1/geods-poi/markers/all.zip", internal.MarkersZip(markers))
+	r.POST("/v1/geods-poi/markers/reload", internal.AdminAuth(), internal.ReloadMarkers(cfg.markerMappings, markers))
+	r.GET("/v1/geods-poi/diagnostics/unmapped-categories", internal.UnmappedCategories(summaries))
+	r.GET("/v1/geods-poi/image/:category", internal.Image(cache, imageQueries, cfg.fallbackImageURL))
+	r.GET("/v1/geods-poi/image/:category/raw", internal.RawImage(cache, imageQueries))
+	r.GET("/v1/geods-poi/image/:category/track", internal.TrackImage(cache, imageQueries))
+	r.GET("/v1/geods-poi/category/:category", internal.Category(summaries, labels, cache, imageQueries, cfg.fallbackImageURL))
+
+	addr := fmt.Sprintf(":%d", cfg.port)
+	if cfg.tlsCert != "" {
+		log.Printf("Starting HTTPS API Server (HTTP/1.1 + HTTP/2) on port %d...", cfg.port)
+		err = r.RunTLS(addr, cfg.tlsCert, cfg.tlsKey)
+	} else {
+		if cfg.http2 {
+			log.Printf("Starting HTTP API Server (HTTP/1.1 + h2c) on port %d...", cfg.port)
+		} else {
+			log.Printf("Starting HTTP API Server on port %d...", cfg.port)
+		}
+		err = r.Run(addr)
+	}
+	log.Fatalf("HTTP API Server failed to start on port %d: %v", cfg.port, err)
 }
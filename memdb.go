@@ -0,0 +1,24 @@
+//go:build memdb
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"geods-poi-api/internal"
+	"geods-poi-api/internal/testutil"
+)
+
+// openMemoryDB serves the test fixtures from an in-memory database, through
+// the same driver, PRAGMAs and busy handling as a GeoPackage file.
+func openMemoryDB(busyTimeout time.Duration) (*sql.DB, error) {
+	db, err := testutil.Open(internal.SQLiteDriver, fmt.Sprintf("_busy_timeout=%d", busyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("WARNING: serving %d test fixtures from an in-memory database; nothing is persisted", len(testutil.Fixtures))
+	return db, nil
+}
@@ -0,0 +1,15 @@
+//go:build !memdb
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// openMemoryDB is only available in development builds, keeping the test
+// fixtures out of the production binary.
+func openMemoryDB(time.Duration) (*sql.DB, error) {
+	return nil, fmt.Errorf("--db %s needs a build with -tags memdb", memoryDB)
+}